@@ -0,0 +1,486 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"revenue-intelligence-api/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix prefixes the environment variable of every setting, e.g.
+// RI_LISTEN_ADDR for listen_addr.
+const EnvPrefix = "RI_"
+
+// DefaultCORSOrigin is the frontend's development server, the only origin
+// allowed unless cors_origins is set.
+const DefaultCORSOrigin = "http://localhost:5173"
+
+var (
+	LogLevels  = []string{"debug", "info", "warn", "error"}
+	LogFormats = []string{"text", "json"}
+)
+
+// Config is the server configuration. It is built from Default, then the
+// config file, then environment variables, then command-line flags, each
+// overriding the previous.
+type Config struct {
+	// DataPath is the directory holding the dataset. When empty, the first
+	// of data and ../data relative to the working directory, then to the
+	// executable, that holds deals.json is used.
+	DataPath string `json:"data_path"`
+
+	// RulesPath and FeedbackPath default to risk_rules.json and
+	// recommendation_feedback.json in DataPath.
+	RulesPath    string `json:"rules_path"`
+	FeedbackPath string `json:"feedback_path"`
+
+	ListenAddr string `json:"listen_addr"`
+
+	// CORSOrigins lists the origins allowed to call the API; "*" allows any.
+	// CORSAllowCredentials needs explicit origins.
+	CORSOrigins          []string `json:"cors_origins"`
+	CORSAllowCredentials bool     `json:"cors_allow_credentials"`
+	CORSMaxAge           Duration `json:"cors_max_age"`
+
+	LogLevel  string `json:"log_level"`
+	LogFormat string `json:"log_format"`
+
+	Auth Auth `json:"auth"`
+
+	// MaxDataAge is how long after loading the dataset /readyz reports the
+	// data as stale; 0 never does. Staleness does not fail readiness.
+	MaxDataAge Duration `json:"max_data_age"`
+
+	Timeouts   Timeouts                `json:"timeouts"`
+	Thresholds services.Thresholds     `json:"thresholds"`
+	Calendar   services.FiscalCalendar `json:"fiscal_calendar"`
+}
+
+// Auth enables authentication when JWTSecret or APIKeysPath is set.
+type Auth struct {
+	// JWTSecret is the HS256 key tokens are signed with, at least 32 bytes.
+	JWTSecret   Secret `json:"jwt_secret"`
+	JWTIssuer   string `json:"jwt_issuer"`
+	JWTAudience string `json:"jwt_audience"`
+
+	// APIKeysPath is a JSON file of static keys, see handlers.APIKey.
+	APIKeysPath string `json:"api_keys_path"`
+}
+
+// minJWTSecretLength is the HS256 key size recommended by RFC 7518.
+const minJWTSecretLength = 32
+
+// Secret is a string that is redacted when the configuration is printed.
+type Secret string
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if s == "" {
+		return json.Marshal("")
+	}
+	return json.Marshal("REDACTED")
+}
+
+type Timeouts struct {
+	ReadHeader Duration `json:"read_header"`
+	Read       Duration `json:"read"`
+	Idle       Duration `json:"idle"`
+
+	// Request bounds each request's computation; Write must be longer so a
+	// timed-out request can still be answered.
+	Request Duration `json:"request"`
+	Write   Duration `json:"write"`
+
+	// Shutdown is how long in-flight requests may take to finish after
+	// SIGINT or SIGTERM.
+	Shutdown Duration `json:"shutdown"`
+}
+
+// Duration is a time.Duration written as a string such as "20s" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"20s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func Default() Config {
+	return Config{
+		ListenAddr:  ":8080",
+		CORSOrigins: []string{DefaultCORSOrigin},
+		CORSMaxAge:  Duration(10 * time.Minute),
+		LogLevel:    "info",
+		LogFormat:   "text",
+		Timeouts: Timeouts{
+			ReadHeader: Duration(5 * time.Second),
+			Read:       Duration(10 * time.Second),
+			Idle:       Duration(120 * time.Second),
+			Request:    Duration(20 * time.Second),
+			Write:      Duration(30 * time.Second),
+			Shutdown:   Duration(15 * time.Second),
+		},
+		Thresholds: services.DefaultThresholds,
+		Calendar:   services.DefaultFiscalCalendar,
+	}
+}
+
+// setting is a value that can be set from the environment and a flag.
+type setting struct {
+	name  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(s.name, "-", "_"))
+}
+
+var settings = []setting{
+	{"data-path", "directory holding the dataset", func(c *Config, v string) error { c.DataPath = v; return nil }},
+	{"rules-path", "risk rules file (default risk_rules.json in the data path)", func(c *Config, v string) error { c.RulesPath = v; return nil }},
+	{"feedback-path", "recommendation feedback file (default recommendation_feedback.json in the data path)", func(c *Config, v string) error { c.FeedbackPath = v; return nil }},
+	{"listen-addr", "address to listen on, e.g. :8080", func(c *Config, v string) error { c.ListenAddr = v; return nil }},
+	{"cors-origins", "comma-separated origins allowed to call the API, or *", func(c *Config, v string) error { c.CORSOrigins = splitList(v); return nil }},
+	{"cors-allow-credentials", "let browsers send credentials from cors-origins", boolSetter(func(c *Config) *bool { return &c.CORSAllowCredentials })},
+	{"cors-max-age", "how long browsers may cache a preflight response", durationSetter(func(c *Config) *Duration { return &c.CORSMaxAge })},
+	{"log-level", "one of " + strings.Join(LogLevels, ", "), func(c *Config, v string) error { c.LogLevel = v; return nil }},
+	{"log-format", "one of " + strings.Join(LogFormats, ", "), func(c *Config, v string) error { c.LogFormat = v; return nil }},
+	{"jwt-secret", "HS256 key for bearer tokens, at least 32 bytes; prefer the environment variable", func(c *Config, v string) error { c.Auth.JWTSecret = Secret(v); return nil }},
+	{"jwt-issuer", "required iss claim of bearer tokens", func(c *Config, v string) error { c.Auth.JWTIssuer = v; return nil }},
+	{"jwt-audience", "required aud claim of bearer tokens", func(c *Config, v string) error { c.Auth.JWTAudience = v; return nil }},
+	{"api-keys-path", "JSON file of static API keys", func(c *Config, v string) error { c.Auth.APIKeysPath = v; return nil }},
+	{"max-data-age", "time after loading the data at which readiness reports it stale, or 0 to never", durationSetter(func(c *Config) *Duration { return &c.MaxDataAge })},
+	{"read-header-timeout", "time allowed to read request headers", durationSetter(func(c *Config) *Duration { return &c.Timeouts.ReadHeader })},
+	{"read-timeout", "time allowed to read a request", durationSetter(func(c *Config) *Duration { return &c.Timeouts.Read })},
+	{"idle-timeout", "time an idle keep-alive connection is kept", durationSetter(func(c *Config) *Duration { return &c.Timeouts.Idle })},
+	{"request-timeout", "time allowed to compute a response", durationSetter(func(c *Config) *Duration { return &c.Timeouts.Request })},
+	{"write-timeout", "time allowed to write a response", durationSetter(func(c *Config) *Duration { return &c.Timeouts.Write })},
+	{"shutdown-timeout", "time in-flight requests may take to finish on shutdown", durationSetter(func(c *Config) *Duration { return &c.Timeouts.Shutdown })},
+	{"min-segment-sample", "closed deals below which a segment uses the figures of all deals", intSetter(func(c *Config) *int { return &c.Thresholds.MinSegmentSample })},
+	{"pipeline-coverage-target", "healthy ratio of open pipeline to next quarter's target", floatSetter(func(c *Config) *float64 { return &c.Thresholds.PipelineCoverageTarget })},
+	{"low-activity-per-deal", "activities per open deal below which a segment is recommended more activity", floatSetter(func(c *Config) *float64 { return &c.Thresholds.LowActivityPerDeal })},
+	{"max-recommendations", "number of recommendations returned", intSetter(func(c *Config) *int { return &c.Thresholds.MaxRecommendations })},
+	{"fiscal-year-start-month", "month the fiscal year starts in, 1-12", intSetter(func(c *Config) *int { return &c.Calendar.StartMonth })},
+	{"current-quarter", "fiscal quarter treated as current, 1-4, or 0 for the quarter containing today", intSetter(func(c *Config) *int { return &c.Calendar.CurrentQuarter })},
+	{"current-year", "fiscal year of current-quarter", intSetter(func(c *Config) *int { return &c.Calendar.CurrentYear })},
+}
+
+func durationSetter(field func(*Config) *Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = Duration(d)
+		return nil
+	}
+}
+
+func intSetter(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func boolSetter(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func floatSetter(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func splitList(v string) []string {
+	list := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Load builds the configuration from the command-line arguments (without
+// the program name) and environment. The config file is given by -config or
+// RI_CONFIG; relative paths in it are resolved against its directory. It
+// reports whether -print-config was given, and returns flag.ErrHelp for
+// -help after printing the usage to output.
+func Load(args []string, getenv func(string) string, output io.Writer) (Config, bool, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("revenue-intelligence-api", flag.ContinueOnError)
+	fs.SetOutput(output)
+	configPath := fs.String("config", getenv(EnvPrefix+"CONFIG"), "JSON config file (env "+EnvPrefix+"CONFIG)")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration as JSON and exit")
+
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	var flagValues []flagValue
+	for _, s := range settings {
+		fs.Func(s.name, s.usage+" (env "+s.env()+")", func(v string) error {
+			flagValues = append(flagValues, flagValue{s, v})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, false, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, false, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return cfg, false, err
+		}
+	}
+	for _, s := range settings {
+		if v := getenv(s.env()); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return cfg, false, fmt.Errorf("%s: %w", s.env(), err)
+			}
+		}
+	}
+	for _, fv := range flagValues {
+		if err := fv.setting.set(&cfg, fv.value); err != nil {
+			return cfg, false, fmt.Errorf("-%s: %w", fv.setting.name, err)
+		}
+	}
+
+	if err := cfg.resolvePaths(); err != nil {
+		return cfg, false, err
+	}
+	return cfg, *printConfig, cfg.Validate()
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, p := range []*string{&c.DataPath, &c.RulesPath, &c.FeedbackPath, &c.Auth.APIKeysPath} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	return nil
+}
+
+// resolvePaths finds the data directory if none is set, fills in the
+// default rules and feedback files, and makes every path absolute.
+func (c *Config) resolvePaths() error {
+	if c.DataPath == "" {
+		candidates := []string{"data", filepath.Join("..", "data")}
+		if exe, err := os.Executable(); err == nil {
+			dir := filepath.Dir(exe)
+			candidates = append(candidates, filepath.Join(dir, "data"), filepath.Join(dir, "..", "data"))
+		}
+		for _, candidate := range candidates {
+			if _, err := os.Stat(filepath.Join(candidate, "deals.json")); err == nil {
+				c.DataPath = candidate
+				break
+			}
+		}
+		if c.DataPath == "" {
+			return fmt.Errorf("no data directory found in %s; set data_path", strings.Join(candidates, ", "))
+		}
+	}
+	if c.RulesPath == "" {
+		c.RulesPath = filepath.Join(c.DataPath, "risk_rules.json")
+	}
+	if c.FeedbackPath == "" {
+		c.FeedbackPath = filepath.Join(c.DataPath, "recommendation_feedback.json")
+	}
+
+	for _, p := range []*string{&c.DataPath, &c.RulesPath, &c.FeedbackPath, &c.Auth.APIKeysPath} {
+		if *p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(*p)
+		if err != nil {
+			return err
+		}
+		*p = abs
+	}
+	return nil
+}
+
+// Validate reports every invalid setting.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if info, err := os.Stat(c.DataPath); err != nil || !info.IsDir() {
+		invalid("data_path %q is not a directory", c.DataPath)
+	}
+	if info, err := os.Stat(c.RulesPath); err != nil || info.IsDir() {
+		invalid("rules_path %q is not a file", c.RulesPath)
+	}
+	if info, err := os.Stat(filepath.Dir(c.FeedbackPath)); err != nil || !info.IsDir() {
+		invalid("feedback_path %q is not in an existing directory", c.FeedbackPath)
+	}
+
+	if _, port, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		invalid("listen_addr %q: %v", c.ListenAddr, err)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		invalid("listen_addr %q has an invalid port", c.ListenAddr)
+	}
+
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			if c.CORSAllowCredentials {
+				invalid("cors_allow_credentials needs explicit cors_origins, not *")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			invalid("cors_origins: %q is not * or a scheme://host[:port] origin", origin)
+		}
+	}
+
+	if c.Auth.JWTSecret != "" && len(c.Auth.JWTSecret) < minJWTSecretLength {
+		invalid("auth.jwt_secret must be at least %d bytes", minJWTSecretLength)
+	}
+	if c.Auth.APIKeysPath != "" {
+		if info, err := os.Stat(c.Auth.APIKeysPath); err != nil || info.IsDir() {
+			invalid("auth.api_keys_path %q is not a file", c.Auth.APIKeysPath)
+		}
+	}
+
+	if c.CORSMaxAge < 0 {
+		invalid("cors_max_age must not be negative")
+	}
+	if c.MaxDataAge < 0 {
+		invalid("max_data_age must not be negative")
+	}
+
+	if !contains(LogLevels, c.LogLevel) {
+		invalid("log_level %q is not one of %s", c.LogLevel, strings.Join(LogLevels, ", "))
+	}
+	if !contains(LogFormats, c.LogFormat) {
+		invalid("log_format %q is not one of %s", c.LogFormat, strings.Join(LogFormats, ", "))
+	}
+
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"read_header", c.Timeouts.ReadHeader},
+		{"read", c.Timeouts.Read},
+		{"idle", c.Timeouts.Idle},
+		{"request", c.Timeouts.Request},
+		{"write", c.Timeouts.Write},
+		{"shutdown", c.Timeouts.Shutdown},
+	}
+	for _, t := range timeouts {
+		if t.value <= 0 {
+			invalid("timeouts.%s must be positive", t.name)
+		}
+	}
+	if c.Timeouts.Write <= c.Timeouts.Request {
+		invalid("timeouts.write (%s) must be longer than timeouts.request (%s)",
+			time.Duration(c.Timeouts.Write), time.Duration(c.Timeouts.Request))
+	}
+
+	if c.Thresholds.MinSegmentSample < 1 {
+		invalid("thresholds.min_segment_sample must be at least 1")
+	}
+	if c.Thresholds.PipelineCoverageTarget <= 0 {
+		invalid("thresholds.pipeline_coverage_target must be positive")
+	}
+	if c.Thresholds.LowActivityPerDeal < 0 {
+		invalid("thresholds.low_activity_per_deal must not be negative")
+	}
+	if c.Thresholds.MaxRecommendations < 1 {
+		invalid("thresholds.max_recommendations must be at least 1")
+	}
+
+	if c.Calendar.StartMonth < 1 || c.Calendar.StartMonth > 12 {
+		invalid("fiscal_calendar.start_month must be 1-12")
+	}
+	if c.Calendar.CurrentQuarter < 0 || c.Calendar.CurrentQuarter > 4 {
+		invalid("fiscal_calendar.current_quarter must be 0-4")
+	}
+	if c.Calendar.CurrentQuarter != 0 && (c.Calendar.CurrentYear < 1900 || c.Calendar.CurrentYear > 9999) {
+		invalid("fiscal_calendar.current_year must be set when current_quarter is")
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a Auth) Enabled() bool {
+	return a.JWTSecret != "" || a.APIKeysPath != ""
+}
+
+// Logger returns a logger writing to w in the configured format and level.
+func (c Config) Logger(w io.Writer) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.LogLevel))
+	opts := &slog.HandlerOptions{Level: level}
+	if c.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDataDir returns a directory holding the files Validate looks for.
+func testDataDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"deals.json", "risk_rules.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("[]"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func writeConfigFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func envFunc(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	data := testDataDir(t)
+	file := writeConfigFile(t, t.TempDir(), `{"listen_addr": ":9000", "log_level": "debug", "cors_max_age": "1m"}`)
+
+	tests := []struct {
+		name       string
+		args       []string
+		env        map[string]string
+		listenAddr string
+		logLevel   string
+		maxAge     time.Duration
+	}{
+		{
+			name:       "defaults",
+			args:       []string{"-data-path", data},
+			listenAddr: ":8080",
+			logLevel:   "info",
+			maxAge:     10 * time.Minute,
+		},
+		{
+			name:       "file over defaults",
+			args:       []string{"-data-path", data, "-config", file},
+			listenAddr: ":9000",
+			logLevel:   "debug",
+			maxAge:     time.Minute,
+		},
+		{
+			name:       "config file from env",
+			args:       []string{"-data-path", data},
+			env:        map[string]string{"RI_CONFIG": file},
+			listenAddr: ":9000",
+			logLevel:   "debug",
+			maxAge:     time.Minute,
+		},
+		{
+			name:       "env over file",
+			args:       []string{"-data-path", data, "-config", file},
+			env:        map[string]string{"RI_LISTEN_ADDR": ":9100", "RI_CORS_MAX_AGE": "2m"},
+			listenAddr: ":9100",
+			logLevel:   "debug",
+			maxAge:     2 * time.Minute,
+		},
+		{
+			name:       "flags over env",
+			args:       []string{"-data-path", data, "-config", file, "-listen-addr", ":9200", "-log-level", "warn"},
+			env:        map[string]string{"RI_LISTEN_ADDR": ":9100", "RI_LOG_LEVEL": "error"},
+			listenAddr: ":9200",
+			logLevel:   "warn",
+			maxAge:     time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, printConfig, err := Load(tt.args, envFunc(tt.env), io.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if printConfig {
+				t.Error("print-config reported without -print-config")
+			}
+			if cfg.ListenAddr != tt.listenAddr {
+				t.Errorf("listen_addr = %q, want %q", cfg.ListenAddr, tt.listenAddr)
+			}
+			if cfg.LogLevel != tt.logLevel {
+				t.Errorf("log_level = %q, want %q", cfg.LogLevel, tt.logLevel)
+			}
+			if got := time.Duration(cfg.CORSMaxAge); got != tt.maxAge {
+				t.Errorf("cors_max_age = %s, want %s", got, tt.maxAge)
+			}
+		})
+	}
+}
+
+func TestLoadResolvesPaths(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "dataset")
+	if err := os.Mkdir(data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"deals.json", "risk_rules.json"} {
+		if err := os.WriteFile(filepath.Join(data, name), []byte("[]"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := writeConfigFile(t, dir, `{"data_path": "dataset"}`)
+
+	cfg, printConfig, err := Load([]string{"-config", file, "-print-config"}, envFunc(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !printConfig {
+		t.Error("-print-config not reported")
+	}
+	if cfg.DataPath != data {
+		t.Errorf("data_path = %q, want %q relative to the config file", cfg.DataPath, data)
+	}
+	if want := filepath.Join(data, "risk_rules.json"); cfg.RulesPath != want {
+		t.Errorf("rules_path = %q, want %q", cfg.RulesPath, want)
+	}
+	if want := filepath.Join(data, "recommendation_feedback.json"); cfg.FeedbackPath != want {
+		t.Errorf("feedback_path = %q, want %q", cfg.FeedbackPath, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	data := testDataDir(t)
+	unknownField := writeConfigFile(t, t.TempDir(), `{"listen_address": ":9000"}`)
+	keys := filepath.Join(t.TempDir(), "keys.json")
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"unexpected argument", []string{"serve"}, nil, `unexpected argument "serve"`},
+		{"unknown file field", []string{"-config", unknownField}, nil, "parse " + unknownField},
+		{"missing config file", []string{"-config", filepath.Join(data, "missing.json")}, nil, "missing.json"},
+		{"bad env value", nil, map[string]string{"RI_MIN_SEGMENT_SAMPLE": "few"}, "RI_MIN_SEGMENT_SAMPLE"},
+		{"bad flag value", []string{"-cors-max-age", "soon"}, nil, "-cors-max-age"},
+		{"data path", []string{"-data-path", filepath.Join(data, "missing")}, nil, "data_path"},
+		{"rules path", []string{"-rules-path", filepath.Join(data, "missing.json")}, nil, "rules_path"},
+		{"feedback path", []string{"-feedback-path", filepath.Join(data, "missing", "feedback.json")}, nil, "feedback_path"},
+		{"listen addr without port", []string{"-listen-addr", "localhost"}, nil, `listen_addr "localhost"`},
+		{"listen addr port", []string{"-listen-addr", ":70000"}, nil, "invalid port"},
+		{"credentials with any origin", []string{"-cors-origins", "*", "-cors-allow-credentials=true"}, nil, "cors_allow_credentials needs explicit cors_origins"},
+		{"cors origin", []string{"-cors-origins", "localhost:5173"}, nil, "cors_origins"},
+		{"cors origin path", []string{"-cors-origins", "https://example.com/app"}, nil, "cors_origins"},
+		{"short jwt secret", nil, map[string]string{"RI_JWT_SECRET": "short"}, "auth.jwt_secret"},
+		{"api keys path", []string{"-api-keys-path", keys}, nil, "auth.api_keys_path"},
+		{"cors max age", []string{"-cors-max-age", "-1s"}, nil, "cors_max_age"},
+		{"max data age", []string{"-max-data-age", "-1h"}, nil, "max_data_age"},
+		{"log level", []string{"-log-level", "verbose"}, nil, "log_level"},
+		{"log format", []string{"-log-format", "xml"}, nil, "log_format"},
+		{"timeout", []string{"-read-timeout", "0s"}, nil, "timeouts.read must be positive"},
+		{"write timeout", []string{"-write-timeout", "10s"}, nil, "timeouts.write (10s) must be longer"},
+		{"min segment sample", []string{"-min-segment-sample", "0"}, nil, "thresholds.min_segment_sample"},
+		{"pipeline coverage target", []string{"-pipeline-coverage-target", "0"}, nil, "thresholds.pipeline_coverage_target"},
+		{"low activity per deal", []string{"-low-activity-per-deal", "-1"}, nil, "thresholds.low_activity_per_deal"},
+		{"max recommendations", []string{"-max-recommendations", "0"}, nil, "thresholds.max_recommendations"},
+		{"fiscal year start", []string{"-fiscal-year-start-month", "13"}, nil, "fiscal_calendar.start_month"},
+		{"current quarter", []string{"-current-quarter", "5"}, nil, "fiscal_calendar.current_quarter"},
+		{"current year", []string{"-current-quarter", "2", "-current-year", "0"}, nil, "fiscal_calendar.current_year"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-data-path", data}, tt.args...)
+			_, _, err := Load(args, envFunc(tt.env), io.Discard)
+			if err == nil {
+				t.Fatalf("Load(%q) succeeded, want an error containing %q", args, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load(%q) = %v, want an error containing %q", args, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	var out strings.Builder
+	_, _, err := Load([]string{"-help"}, envFunc(nil), &out)
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("Load(-help) = %v, want flag.ErrHelp", err)
+	}
+	if !strings.Contains(out.String(), "RI_LISTEN_ADDR") {
+		t.Errorf("usage does not name the environment variables:\n%s", out.String())
+	}
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"revenue-intelligence-api/models"
+	"revenue-intelligence-api/services"
+	"strings"
+	"time"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// jwtLeeway tolerates clock skew between the token issuer and the server.
+const jwtLeeway = time.Minute
+
+// publicPaths are served without authentication.
+var publicPaths = map[string]bool{
+	"/openapi.json": true,
+}
+
+var (
+	errMissingCredentials = errors.New("missing credentials: send Authorization: Bearer <token> or " + APIKeyHeader)
+	errInvalidToken       = errors.New("invalid token")
+)
+
+// APIKey is a static key for one caller. Only the SHA-256 of the key is
+// stored.
+type APIKey struct {
+	Name      string   `json:"name"`
+	KeySHA256 string   `json:"key_sha256"`
+	Role      string   `json:"role"`
+	RepIDs    []string `json:"rep_ids,omitempty"`
+}
+
+// LoadAPIKeys reads a JSON array of APIKey.
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, key := range keys {
+		if b, err := hex.DecodeString(key.KeySHA256); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("%s: key %d (%s): key_sha256 is not a hex SHA-256", path, i, key.Name)
+		}
+		if err := validatePrincipal(key.principal()); err != nil {
+			return nil, fmt.Errorf("%s: key %d (%s): %w", path, i, key.Name, err)
+		}
+	}
+	return keys, nil
+}
+
+func (k APIKey) principal() models.Principal {
+	return models.Principal{Subject: k.Name, Role: k.Role, RepIDs: k.RepIDs}
+}
+
+// Authenticator verifies HS256 JWTs signed with JWTSecret and static API
+// keys. With neither configured, authentication is disabled and every
+// request sees all data.
+type Authenticator struct {
+	JWTSecret   []byte
+	JWTIssuer   string
+	JWTAudience string
+	APIKeys     []APIKey
+}
+
+func (a *Authenticator) Enabled() bool {
+	return a != nil && (len(a.JWTSecret) > 0 || len(a.APIKeys) > 0)
+}
+
+// Handle authenticates the request and passes its Principal to the services
+// through the context, or answers 401.
+func (a *Authenticator) Handle(next http.HandlerFunc) http.HandlerFunc {
+	if !a.Enabled() {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.authenticate(r)
+		if err != nil {
+			challenge := `Bearer realm="revenue-intelligence"`
+			if !errors.Is(err, errMissingCredentials) {
+				challenge += `, error="invalid_token"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, err.Error(), nil)
+			return
+		}
+		setLogSubject(r.Context(), principal.Subject)
+		next(w, r.WithContext(services.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// authenticate takes a JWT or API key from the Authorization header, or an
+// API key from X-API-Key.
+func (a *Authenticator) authenticate(r *http.Request) (models.Principal, error) {
+	credential := r.Header.Get(APIKeyHeader)
+	if credential == "" {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return models.Principal{}, errMissingCredentials
+		}
+		credential = strings.TrimSpace(token)
+		if len(a.JWTSecret) > 0 && strings.Count(credential, ".") == 2 {
+			return a.verifyJWT(credential, time.Now())
+		}
+	}
+	return a.lookupAPIKey(credential)
+}
+
+func (a *Authenticator) lookupAPIKey(key string) (models.Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+	for _, k := range a.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(strings.ToLower(k.KeySHA256))) == 1 {
+			return k.principal(), nil
+		}
+	}
+	return models.Principal{}, errors.New("unknown API key")
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Subject   string      `json:"sub"`
+	Role      string      `json:"role"`
+	RepIDs    []string    `json:"rep_ids"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt *int64      `json:"exp"`
+	NotBefore *int64      `json:"nbf"`
+}
+
+// jwtAudience is the aud claim, a string or an array of strings.
+type jwtAudience []string
+
+func (aud *jwtAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*aud = jwtAudience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*aud = list
+	return nil
+}
+
+// verifyJWT checks an HS256 token's signature, expiry (required), not-before,
+// issuer and audience, and returns the caller its claims describe.
+func (a *Authenticator) verifyJWT(token string, now time.Time) (models.Principal, error) {
+	parts := strings.Split(token, ".")
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return models.Principal{}, err
+	}
+	if header.Alg != "HS256" {
+		return models.Principal{}, fmt.Errorf("%w: unsupported alg %q", errInvalidToken, header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return models.Principal{}, fmt.Errorf("%w: malformed signature", errInvalidToken)
+	}
+	mac := hmac.New(sha256.New, a.JWTSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return models.Principal{}, fmt.Errorf("%w: bad signature", errInvalidToken)
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return models.Principal{}, err
+	}
+	if claims.ExpiresAt == nil {
+		return models.Principal{}, fmt.Errorf("%w: no exp claim", errInvalidToken)
+	}
+	if now.After(time.Unix(*claims.ExpiresAt, 0).Add(jwtLeeway)) {
+		return models.Principal{}, fmt.Errorf("%w: expired", errInvalidToken)
+	}
+	if claims.NotBefore != nil && now.Add(jwtLeeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return models.Principal{}, fmt.Errorf("%w: not yet valid", errInvalidToken)
+	}
+	if a.JWTIssuer != "" && claims.Issuer != a.JWTIssuer {
+		return models.Principal{}, fmt.Errorf("%w: wrong issuer", errInvalidToken)
+	}
+	if a.JWTAudience != "" && !contains(claims.Audience, a.JWTAudience) {
+		return models.Principal{}, fmt.Errorf("%w: wrong audience", errInvalidToken)
+	}
+
+	principal := models.Principal{Subject: claims.Subject, Role: claims.Role, RepIDs: claims.RepIDs}
+	if err := validatePrincipal(principal); err != nil {
+		return models.Principal{}, fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+	return principal, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("%w: malformed encoding", errInvalidToken)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: malformed JSON", errInvalidToken)
+	}
+	return nil
+}
+
+// validatePrincipal checks the role and that managers name their team and
+// reps themselves.
+func validatePrincipal(p models.Principal) error {
+	switch p.Role {
+	case models.RoleCRO:
+	case models.RoleManager:
+		if len(p.RepIDs) == 0 {
+			return errors.New("manager needs rep_ids")
+		}
+	case models.RoleRep:
+		if len(p.RepIDs) != 1 {
+			return errors.New("rep needs exactly one rep_id")
+		}
+	default:
+		return fmt.Errorf("unknown role %q", p.Role)
+	}
+	return nil
+}
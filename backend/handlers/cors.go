@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	corsAllowedHeaders = []string{"Authorization", "Content-Type", "If-None-Match", APIKeyHeader, RequestIDHeader}
+	corsExposedHeaders = []string{"ETag", RequestIDHeader}
+)
+
+// CORSPolicy decides which browser origins may call the API. The zero value
+// allows no cross-origin requests.
+type CORSPolicy struct {
+	// Origins lists the allowed origins as scheme://host[:port]; "*"
+	// allows any origin.
+	Origins []string
+
+	// AllowCredentials lets browsers send cookies and Authorization
+	// headers. It needs explicit Origins: the allowed origin is echoed,
+	// never "*".
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+func (p CORSPolicy) anyOrigin() bool {
+	for _, o := range p.Origins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p CORSPolicy) allows(origin string) bool {
+	if p.anyOrigin() {
+		return true
+	}
+	for _, o := range p.Origins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// setOrigin writes the headers shared by preflight and actual responses to
+// an allowed origin. Responses vary by Origin unless every origin gets the
+// same "*".
+func (p CORSPolicy) setOrigin(w http.ResponseWriter, origin string) {
+	if p.anyOrigin() && !p.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if p.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p CORSPolicy) varies() bool {
+	return !p.anyOrigin() || p.AllowCredentials
+}
+
+// Handle adds CORS headers to actual (non-preflight) responses for allowed
+// origins. Requests from other origins are served without them, so browsers
+// withhold the response from the calling page.
+func (p CORSPolicy) Handle(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p.varies() {
+			w.Header().Add("Vary", "Origin")
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && p.allows(origin) {
+			p.setOrigin(w, origin)
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		}
+		next(w, r)
+	}
+}
+
+// Preflight answers OPTIONS requests for a path served with methods. A
+// preflight from a disallowed origin gets 403, and one for a method the
+// path does not serve gets 405; a plain OPTIONS request gets the Allow
+// header.
+func (p CORSPolicy) Preflight(methods []string) http.HandlerFunc {
+	allow := strings.Join(append(append([]string{}, methods...), http.MethodOptions), ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		origin := r.Header.Get("Origin")
+		requested := r.Header.Get("Access-Control-Request-Method")
+		if origin == "" || requested == "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !p.allows(origin) {
+			writeError(w, r, http.StatusForbidden, CodeForbidden, "Origin not allowed", map[string]interface{}{"origin": origin})
+			return
+		}
+		p.setOrigin(w, origin)
+
+		if !contains(methods, requested) {
+			writeError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed", map[string]interface{}{"allowed": methods})
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+		if p.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
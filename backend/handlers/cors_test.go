@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"revenue-intelligence-api/config"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	testOrigin    = "https://app.example.com"
+	foreignOrigin = "https://evil.example.com"
+)
+
+func preflight(policy CORSPolicy, origin, method string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/summary", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if method != "" {
+		req.Header.Set("Access-Control-Request-Method", method)
+	}
+	rec := httptest.NewRecorder()
+	policy.Preflight([]string{http.MethodGet})(rec, req)
+	return rec
+}
+
+func actual(policy CORSPolicy, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/summary", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	rec := httptest.NewRecorder()
+	policy.Handle(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})(rec, req)
+	return rec
+}
+
+func TestPreflight(t *testing.T) {
+	policy := CORSPolicy{Origins: []string{testOrigin}, MaxAge: 10 * time.Minute}
+
+	tests := []struct {
+		name       string
+		origin     string
+		method     string
+		wantStatus int
+		wantOrigin string
+		wantHeader map[string]string
+	}{
+		{
+			name: "allowed", origin: testOrigin, method: http.MethodGet,
+			wantStatus: http.StatusNoContent, wantOrigin: testOrigin,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type, If-None-Match, X-API-Key, X-Request-ID",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{name: "disallowed origin", origin: foreignOrigin, method: http.MethodGet, wantStatus: http.StatusForbidden},
+		{
+			name: "disallowed method", origin: testOrigin, method: http.MethodDelete,
+			wantStatus: http.StatusMethodNotAllowed, wantOrigin: testOrigin,
+			wantHeader: map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+		{
+			name: "plain OPTIONS", wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{"Allow": "GET, OPTIONS"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := preflight(policy, tt.origin, tt.method)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			for name, want := range tt.wantHeader {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if tt.origin != "" && !slices.Contains(rec.Header().Values("Vary"), "Origin") {
+				t.Errorf("Vary = %q, want Origin", rec.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          CORSPolicy
+		origin          string
+		wantOrigin      string
+		wantCredentials string
+		wantVary        bool
+	}{
+		{name: "allowed origin", policy: CORSPolicy{Origins: []string{testOrigin}}, origin: testOrigin, wantOrigin: testOrigin, wantVary: true},
+		{name: "disallowed origin", policy: CORSPolicy{Origins: []string{testOrigin}}, origin: foreignOrigin, wantVary: true},
+		{name: "any origin", policy: CORSPolicy{Origins: []string{"*"}}, origin: foreignOrigin, wantOrigin: "*"},
+		{
+			name: "credentials echo the origin", policy: CORSPolicy{Origins: []string{testOrigin}, AllowCredentials: true},
+			origin: testOrigin, wantOrigin: testOrigin, wantCredentials: "true", wantVary: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := actual(tt.policy, tt.origin)
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+			if got := slices.Contains(rec.Header().Values("Vary"), "Origin"); got != tt.wantVary {
+				t.Errorf("Vary: Origin = %v, want %v", got, tt.wantVary)
+			}
+		})
+	}
+}
+
+func TestDefaultPolicyRejectsForeignOrigins(t *testing.T) {
+	policy := CORSPolicy{Origins: config.Default().CORSOrigins}
+	if rec := preflight(policy, foreignOrigin, http.MethodGet); rec.Code != http.StatusForbidden {
+		t.Errorf("preflight from %s: status = %d, want %d", foreignOrigin, rec.Code, http.StatusForbidden)
+	}
+	if rec := preflight(policy, config.DefaultCORSOrigin, http.MethodGet); rec.Code != http.StatusNoContent {
+		t.Errorf("preflight from %s: status = %d, want %d", config.DefaultCORSOrigin, rec.Code, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"revenue-intelligence-api/models"
+	"revenue-intelligence-api/services"
+)
+
+const (
+	CodeInvalidParameter = "invalid_parameter"
+	CodeInvalidRequest   = "invalid_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeUnprocessable    = "unprocessable_entity"
+	CodeInternal         = "internal_error"
+	CodeTimeout          = "timeout"
+)
+
+// serviceErrors maps the services' sentinel errors, and the context errors
+// they return once a request's deadline passes, to a status and code.
+var serviceErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{services.ErrRiskTypeNotFound, http.StatusNotFound, CodeNotFound},
+	{services.ErrRecommendationNotFound, http.StatusNotFound, CodeNotFound},
+	{services.ErrDealNotFound, http.StatusNotFound, CodeNotFound},
+	{services.ErrRepNotFound, http.StatusNotFound, CodeNotFound},
+	{services.ErrForbidden, http.StatusForbidden, CodeForbidden},
+	{services.ErrRecommendationNotEvaluable, http.StatusConflict, CodeConflict},
+	{services.ErrDealClosed, http.StatusConflict, CodeConflict},
+	{context.DeadlineExceeded, http.StatusServiceUnavailable, CodeTimeout},
+	{context.Canceled, http.StatusServiceUnavailable, CodeTimeout},
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the JSON error envelope used by every endpoint.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string, details map[string]interface{}) {
+	writeJSON(w, status, models.ErrorResponse{Error: models.APIError{
+		Code:      code,
+		Message:   message,
+		RequestID: RequestIDFromContext(r.Context()),
+		Details:   details,
+	}})
+}
+
+func writeInvalidParameter(w http.ResponseWriter, r *http.Request, name string) {
+	writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid "+name+" parameter", map[string]interface{}{"parameter": name})
+}
+
+// writeServiceError writes err with the status of its sentinel error, or
+// with status and code when it is not one.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error, status int, code string) {
+	for _, se := range serviceErrors {
+		if errors.Is(err, se.err) {
+			status, code = se.status, se.code
+			break
+		}
+	}
+	writeError(w, r, status, code, err.Error(), nil)
+}
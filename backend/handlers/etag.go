@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"revenue-intelligence-api/services"
+	"strings"
+)
+
+// cacheControl lets clients and shared caches keep responses but makes them
+// revalidate each use, which the ETag answers cheaply with 304 until the
+// data changes. Responses depend on the caller, so only private caches may
+// store them.
+const cacheControl = "private, no-cache"
+
+type snapshotKey struct{}
+
+// withETag tags responses of read-only routes with an ETag derived from the
+// dataset version, the path, the query and the caller's scope, and answers
+// 304 to a matching If-None-Match without computing the response. The
+// version is that of a Snapshot pinned for the request, which handlers
+// compute from (see analytics), so a reload or feedback change while the
+// response is computed cannot leave it tagged with another version.
+func (h *Handlers) withETag(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot := h.AnalyticsService.Snapshot(h.AnalyticsService.DataService.Now())
+		etag := etagFor(snapshot.Version(), r)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", APIKeyHeader)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		ctx := context.WithValue(r.Context(), snapshotKey{}, snapshot)
+		next(&etagWriter{ResponseWriter: w, etag: etag}, r.WithContext(ctx))
+	}
+}
+
+// analytics returns the snapshot withETag pinned for r, or the live service
+// for requests without one.
+func (h *Handlers) analytics(r *http.Request) *services.AnalyticsService {
+	if snapshot, ok := r.Context().Value(snapshotKey{}).(*services.AnalyticsService); ok {
+		return snapshot
+	}
+	return h.AnalyticsService
+}
+
+// simulation returns the simulation service with the present pinned to that
+// of the snapshot withETag pinned for r, if any.
+func (h *Handlers) simulation(r *http.Request) *services.SimulationService {
+	snapshot, ok := r.Context().Value(snapshotKey{}).(*services.AnalyticsService)
+	if !ok {
+		return h.SimulationService
+	}
+	ss := *h.SimulationService
+	ss.DataService = ss.DataService.At(snapshot.DataService.Now())
+	return &ss
+}
+
+func etagFor(version string, r *http.Request) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		version,
+		services.ScopeKey(r.Context()),
+		r.URL.Path,
+		r.URL.Query().Encode(),
+	}, "\n")))
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// etagMatches implements the weak comparison If-None-Match uses. "*" is not
+// matched: whether a representation exists is only known by computing it.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagWriter sets the ETag on successful responses only, so errors are not
+// revalidated as if they were the resource.
+type etagWriter struct {
+	http.ResponseWriter
+	etag        string
+	wroteHeader bool
+}
+
+func (w *etagWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status == http.StatusOK {
+			w.Header().Set("ETag", w.etag)
+		} else {
+			w.Header().Del("Cache-Control")
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
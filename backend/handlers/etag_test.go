@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"revenue-intelligence-api/models"
+	"testing"
+)
+
+func dismiss(fb *models.RecommendationFeedback) { fb.Status = models.RecommendationDismissed }
+
+func TestETagRevalidation(t *testing.T) {
+	h := newTestHandlers(t)
+	router := NewRouter(h, RouterOptions{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, APIPrefix+"/summary", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q; want 200 with an ETag", first.Code, etag)
+	}
+	if rec := get(etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("revalidation: status = %d, body %q; want an empty 304", rec.Code, rec.Body)
+	}
+
+	if _, err := h.AnalyticsService.Feedback.Update("x", dismiss); err != nil {
+		t.Fatal(err)
+	}
+	rec := get(etag)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("after a change: status = %d, ETag = %q; want 200 with a new ETag", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+// TestETagMatchesServedSnapshot saves feedback while the response is being
+// computed; the ETag must still describe the version the handler used.
+func TestETagMatchesServedSnapshot(t *testing.T) {
+	h := newTestHandlers(t)
+	var served string
+	handler := h.withETag(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.AnalyticsService.Feedback.Update("x", dismiss); err != nil {
+			t.Fatal(err)
+		}
+		served = h.analytics(r).Version()
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, APIPrefix+"/summary", nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if got, want := rec.Header().Get("ETag"), etagFor(served, req); got != want {
+		t.Errorf("ETag = %s, want %s for the version served", got, want)
+	}
+	if served == h.AnalyticsService.Version() {
+		t.Error("the handler saw the change made after its snapshot")
+	}
+}
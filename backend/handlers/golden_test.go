@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"revenue-intelligence-api/services"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenNow pins the present, so the as-of date does not follow the clock.
+var goldenNow = time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+// goldenPathValues fills the path parameters of Routes.
+var goldenPathValues = strings.NewReplacer(
+	"{type}", "stale_deals",
+	"{id}", "increase_activity:Mid-Market",
+	"{deal_id}", "D2",
+	"{rep_id}", "R2",
+)
+
+// goldenQueries are extra requests for endpoints whose parameters change
+// the result.
+var goldenQueries = []string{
+	"/drivers?window_days=90",
+	"/risk-factors/stale_deals?sort=amount&order=asc&page=2&page_size=5",
+	"/risk-factors/underperforming_reps",
+	"/risk-factors/low_activity_accounts?page_size=3",
+	"/risk-factors/stale_deals?filter[segment]=SMB&filter[amount]=78822&page_size=2&foo=1",
+	"/trends?metric=win_rate&granularity=quarter",
+	"/cohorts?segment=Enterprise",
+	"/reps/R2/next-actions?limit=3",
+	"/simulation?iterations=500&seed=7",
+}
+
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	return NewRouter(newTestHandlers(t), RouterOptions{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+}
+
+func newTestHandlers(t *testing.T) *Handlers {
+	t.Helper()
+	dataPath := filepath.Join("..", "..", "data")
+	ds, err := services.NewDataService(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := services.NewRuleEngine(filepath.Join(dataPath, "risk_rules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := services.NewRecommendationStore(filepath.Join(t.TempDir(), "feedback.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds = ds.At(goldenNow)
+	return NewHandlers(services.NewAnalyticsService(ds, re, store), services.NewSimulationService(ds))
+}
+
+// TestGoldenResponses requests every GET endpoint from two freshly built
+// routers, so neither response comes from the other's result cache, and
+// compares each status and body with testdata/<name>.golden. Run with
+// -update after an intended change to the output.
+func TestGoldenResponses(t *testing.T) {
+	paths := []string{}
+	for _, route := range (&Handlers{}).Routes() {
+		if route.Method == http.MethodGet {
+			paths = append(paths, goldenPathValues.Replace(route.Path))
+		}
+	}
+	paths = append(paths, goldenQueries...)
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			first := goldenRequest(newTestRouter(t), path)
+			second := goldenRequest(newTestRouter(t), path)
+			if !bytes.Equal(first, second) {
+				t.Fatalf("responses differ between requests:\n%s\n---\n%s", first, second)
+			}
+
+			golden := filepath.Join("testdata", goldenName(path)+".golden")
+			if *update {
+				if err := os.MkdirAll("testdata", 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, first, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if !bytes.Equal(first, want) {
+				t.Errorf("response differs from %s:\n%s", golden, first)
+			}
+		})
+	}
+}
+
+// goldenRequest returns the status line and body of a GET for path.
+func goldenRequest(router http.Handler, path string) []byte {
+	req := httptest.NewRequest(http.MethodGet, APIPrefix+path, nil)
+	req.Header.Set(RequestIDHeader, "golden")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return append([]byte(fmt.Sprintf("%d\n", rec.Code)), rec.Body.Bytes()...)
+}
+
+// goldenName turns a path and query into a file name.
+func goldenName(path string) string {
+	name := strings.Trim(path, "/")
+	return strings.NewReplacer("/", "_", "?", "__", "&", "_", "=", "-", ":", "-", "[", "_", "]", "").Replace(name)
+}
@@ -3,74 +3,301 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"revenue-intelligence-api/models"
 	"revenue-intelligence-api/services"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Handlers struct {
-	AnalyticsService *services.AnalyticsService
+	AnalyticsService  *services.AnalyticsService
+	SimulationService *services.SimulationService
 }
 
-func NewHandlers(as *services.AnalyticsService) *Handlers {
+func NewHandlers(as *services.AnalyticsService, ss *services.SimulationService) *Handlers {
 	return &Handlers{
-		AnalyticsService: as,
+		AnalyticsService:  as,
+		SimulationService: ss,
 	}
 }
 
-func (h *Handlers) GetSummary(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// GetDashboard serves the dashboard's four panels in one response computed
+// from one snapshot.
+func (h *Handlers) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	windowDays := 0
+	if v := r.URL.Query().Get("window_days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeInvalidParameter(w, r, "window_days")
+			return
+		}
+		windowDays = n
+	}
+
+	dashboard, err := h.analytics(r).GetDashboard(r.Context(), windowDays)
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
 		return
 	}
+	writeJSON(w, http.StatusOK, dashboard)
+}
 
-	summary := h.AnalyticsService.GetSummary()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(summary)
+func (h *Handlers) GetSummary(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.analytics(r).GetSummary(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
 }
 
 func (h *Handlers) GetDrivers(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	windowDays := 0
+	if v := r.URL.Query().Get("window_days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeInvalidParameter(w, r, "window_days")
+			return
+		}
+		windowDays = n
 	}
 
-	drivers := h.AnalyticsService.GetRevenueDrivers()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(drivers)
+	drivers, err := h.analytics(r).GetRevenueDrivers(r.Context(), windowDays)
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
+		return
+	}
+	writeJSON(w, http.StatusOK, drivers)
 }
 
 func (h *Handlers) GetRiskFactors(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	risks, err := h.analytics(r).GetRiskFactors(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
 		return
 	}
+	writeJSON(w, http.StatusOK, risks)
+}
+
+func (h *Handlers) GetRiskFactorDetail(w http.ResponseWriter, r *http.Request) {
+	query := services.RiskQuery{Filters: make(map[string]string)}
+	for key, values := range r.URL.Query() {
+		value := values[0]
+		switch key {
+		case "sort":
+			query.Sort = value
+		case "order":
+			if value != "asc" && value != "desc" {
+				writeInvalidParameter(w, r, "order")
+				return
+			}
+			query.Order = value
+		case "page", "page_size":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				writeInvalidParameter(w, r, key)
+				return
+			}
+			if key == "page" {
+				query.Page = n
+			} else {
+				query.PageSize = n
+			}
+		case "min_amount", "max_amount":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				writeInvalidParameter(w, r, key)
+				return
+			}
+			if key == "min_amount" {
+				query.MinAmount = &n
+			} else {
+				query.MaxAmount = &n
+			}
+		default:
+			// Other parameters are ignored, as on every endpoint; filters
+			// are named explicitly so a typo in one is reported.
+			if field, ok := strings.CutPrefix(key, "filter["); ok && strings.HasSuffix(field, "]") {
+				query.Filters[strings.TrimSuffix(field, "]")] = value
+			}
+		}
+	}
 
-	risks := h.AnalyticsService.GetRiskFactors()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(risks)
+	detail, err := h.analytics(r).GetRiskFactorDetail(r.Context(), r.PathValue("type"), query)
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusBadRequest, CodeInvalidParameter)
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
 }
 
 func (h *Handlers) GetRecommendations(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	recommendations, err := h.analytics(r).GetRecommendations(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
+		return
+	}
+	writeJSON(w, http.StatusOK, recommendations)
+}
+
+func (h *Handlers) GetRecommendationFeedback(w http.ResponseWriter, r *http.Request) {
+	feedback, err := h.analytics(r).GetRecommendationFeedback(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
+		return
+	}
+	writeJSON(w, http.StatusOK, feedback)
+}
+
+func (h *Handlers) SetRecommendationFeedback(w http.ResponseWriter, r *http.Request) {
+	var req models.RecommendationFeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body: "+err.Error(), nil)
+		return
+	}
+
+	feedback, err := h.AnalyticsService.SetRecommendationStatus(r.Context(), r.PathValue("id"), req)
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusBadRequest, CodeInvalidRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, feedback)
+}
+
+func (h *Handlers) GetRecommendationOutcome(w http.ResponseWriter, r *http.Request) {
+	outcome, err := h.analytics(r).GetRecommendationOutcome(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
+		return
+	}
+	writeJSON(w, http.StatusOK, outcome)
+}
+
+func (h *Handlers) GetDealNextAction(w http.ResponseWriter, r *http.Request) {
+	action, err := h.analytics(r).GetDealNextAction(r.Context(), r.PathValue("deal_id"))
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
+		return
+	}
+	writeJSON(w, http.StatusOK, action)
+}
+
+func (h *Handlers) GetRepNextActions(w http.ResponseWriter, r *http.Request) {
+	limit := services.DefaultNextActionLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > services.MaxNextActionLimit {
+			writeInvalidParameter(w, r, "limit")
+			return
+		}
+		limit = n
+	}
+
+	actions, err := h.analytics(r).GetRepNextActions(r.Context(), r.PathValue("rep_id"), limit)
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
+		return
+	}
+	writeJSON(w, http.StatusOK, actions)
+}
+
+func (h *Handlers) GetTrends(w http.ResponseWriter, r *http.Request) {
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = "revenue"
+	}
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = "month"
+	}
+
+	trends, err := h.analytics(r).GetTrends(r.Context(), metric, granularity)
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusBadRequest, CodeInvalidParameter)
+		return
+	}
+	writeJSON(w, http.StatusOK, trends)
+}
+
+func (h *Handlers) GetCohorts(w http.ResponseWriter, r *http.Request) {
+	cohorts, err := h.analytics(r).GetCohorts(r.Context(), r.URL.Query().Get("segment"))
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusBadRequest, CodeInvalidParameter)
+		return
+	}
+	writeJSON(w, http.StatusOK, cohorts)
+}
+
+func (h *Handlers) GetActivityEffectiveness(w http.ResponseWriter, r *http.Request) {
+	effectiveness, err := h.analytics(r).GetActivityEffectiveness(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
+		return
+	}
+	writeJSON(w, http.StatusOK, effectiveness)
+}
+
+func (h *Handlers) GetRiskRules(w http.ResponseWriter, r *http.Request) {
+	rules := services.RuleSet{Rules: h.analytics(r).RuleEngine.Rules()}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (h *Handlers) ReloadRiskRules(w http.ResponseWriter, r *http.Request) {
+	if err := services.RequireRole(r.Context(), models.RoleCRO); err != nil {
+		writeServiceError(w, r, err, http.StatusForbidden, CodeForbidden)
+		return
+	}
+	if err := h.AnalyticsService.RuleEngine.Reload(); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, CodeUnprocessable, "Failed to reload rules: "+err.Error(), nil)
 		return
 	}
 
-	recommendations := h.AnalyticsService.GetRecommendations()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(recommendations)
+	rules := services.RuleSet{Rules: h.AnalyticsService.RuleEngine.Rules()}
+	writeJSON(w, http.StatusOK, rules)
 }
 
-func EnableCORS(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// GetHealth reports that the process is serving requests.
+func (h *Handlers) GetHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// GetReadiness answers 200 when the service can take traffic and 503 with
+// the failing checks when it cannot.
+func (h *Handlers) GetReadiness(w http.ResponseWriter, r *http.Request) {
+	readiness := h.AnalyticsService.Readiness(time.Now())
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, readiness)
+}
+
+func (h *Handlers) GetSimulation(w http.ResponseWriter, r *http.Request) {
+	iterations := services.DefaultSimulationIterations
+	if v := r.URL.Query().Get("iterations"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > services.MaxSimulationIterations {
+			writeInvalidParameter(w, r, "iterations")
+			return
+		}
+		iterations = n
+	}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+	seed := int64(services.DefaultSimulationSeed)
+	if v := r.URL.Query().Get("seed"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeInvalidParameter(w, r, "seed")
 			return
 		}
+		seed = n
+	}
 
-		next(w, r)
+	result, err := h.simulation(r).SimulateQuarter(r.Context(), iterations, seed)
+	if err != nil {
+		writeServiceError(w, r, err, http.StatusInternalServerError, CodeInternal)
+		return
 	}
+	writeJSON(w, http.StatusOK, result)
 }
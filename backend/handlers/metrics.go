@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"revenue-intelligence-api/metrics"
+	"revenue-intelligence-api/models"
+	"revenue-intelligence-api/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricsPath serves Prometheus metrics, outside the API prefix.
+const MetricsPath = "/metrics"
+
+// unmatchedRoute labels requests no route matched, so unknown paths do not
+// each create a series.
+const unmatchedRoute = "unmatched"
+
+type httpMetrics struct {
+	registry *metrics.Registry
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+	inFlight *metrics.Gauge
+}
+
+// newHTTPMetrics registers the request metrics and the dataset, rule and
+// business gauges, which are computed from the services on every scrape.
+func newHTTPMetrics(h *Handlers) *httpMetrics {
+	r := metrics.NewRegistry()
+	m := &httpMetrics{
+		registry: r,
+		requests: r.NewCounterVec("revenue_api_http_requests_total",
+			"HTTP requests by method, route pattern and status code.", "method", "route", "status"),
+		duration: r.NewHistogramVec("revenue_api_http_request_duration_seconds",
+			"HTTP request latency by method and route pattern.", metrics.DefaultBuckets, "method", "route"),
+		inFlight: r.NewGauge("revenue_api_http_requests_in_flight", "HTTP requests being served."),
+	}
+
+	ds := h.AnalyticsService.DataService
+	r.NewGaugeFunc("revenue_api_dataset_records", "Records loaded per dataset.", func() []metrics.Sample {
+		return []metrics.Sample{
+			{LabelValues: []string{"accounts"}, Value: float64(len(ds.Accounts))},
+			{LabelValues: []string{"activities"}, Value: float64(len(ds.Activities))},
+			{LabelValues: []string{"deals"}, Value: float64(len(ds.Deals))},
+			{LabelValues: []string{"reps"}, Value: float64(len(ds.Reps))},
+			{LabelValues: []string{"targets"}, Value: float64(len(ds.Targets))},
+		}
+	}, "dataset")
+	r.NewGaugeFunc("revenue_api_data_load_duration_seconds", "Time taken to load and check the dataset.", func() []metrics.Sample {
+		return []metrics.Sample{{Value: ds.LoadDuration.Seconds()}}
+	})
+	r.NewGaugeFunc("revenue_api_data_loaded_timestamp_seconds", "Unix time the dataset was loaded.", func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(ds.LoadedAt.UnixNano()) / 1e9}}
+	})
+	r.NewGaugeFunc("revenue_api_data_quality_violations", "Records failing each data-quality check.", func() []metrics.Sample {
+		samples := []metrics.Sample{}
+		for _, check := range ds.Quality {
+			samples = append(samples, metrics.Sample{LabelValues: []string{check.Check, check.Severity}, Value: float64(check.Violations)})
+		}
+		return samples
+	}, "check", "severity")
+
+	re := h.AnalyticsService.RuleEngine
+	r.NewCounterFunc("revenue_api_rules_loads_total", "Risk rule loads, including the initial one, by result.", func() []metrics.Sample {
+		stats := re.LoadStats()
+		return []metrics.Sample{
+			{LabelValues: []string{"success"}, Value: float64(stats.Loads - stats.Failures)},
+			{LabelValues: []string{"failure"}, Value: float64(stats.Failures)},
+		}
+	}, "result")
+	r.NewGaugeFunc("revenue_api_rules_load_duration_seconds", "Time taken by the last risk rule load.", func() []metrics.Sample {
+		return []metrics.Sample{{Value: re.LoadStats().LastDuration.Seconds()}}
+	})
+
+	r.NewGaugeFunc("revenue_api_quarter_amount", "Current quarter revenue, target and gap to target.", func() []metrics.Sample {
+		summary, err := h.AnalyticsService.GetSummary(context.Background())
+		if err != nil {
+			return nil
+		}
+		return []metrics.Sample{
+			{LabelValues: []string{"revenue"}, Value: summary.Revenue},
+			{LabelValues: []string{"target"}, Value: summary.Target},
+			{LabelValues: []string{"gap"}, Value: summary.Gap},
+		}
+	}, "kind")
+	r.NewGaugeFunc("revenue_api_open_pipeline", "Total amount of open deals.", func() []metrics.Sample {
+		drivers, err := h.AnalyticsService.GetRevenueDrivers(context.Background(), 0)
+		if err != nil {
+			return nil
+		}
+		return []metrics.Sample{{Value: drivers.PipelineSize}}
+	})
+	r.NewGaugeFunc("revenue_api_win_rate_ratio", "Won deals over closed deals, all time.", func() []metrics.Sample {
+		drivers, err := h.AnalyticsService.GetRevenueDrivers(context.Background(), 0)
+		if err != nil {
+			return nil
+		}
+		return []metrics.Sample{{Value: drivers.WinRate / 100}}
+	})
+
+	return m
+}
+
+// instrument counts and times requests by the route pattern routeOf
+// returns for them.
+func (m *httpMetrics) instrument(routeOf func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := routeOf(r)
+		m.requests.Inc(r.Method, route, strconv.Itoa(status))
+		m.duration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// serve writes the metrics. They include company-wide revenue, so with
+// authentication enabled only a CRO may read them.
+func (m *httpMetrics) serve(w http.ResponseWriter, r *http.Request) {
+	if err := services.RequireRole(r.Context(), models.RoleCRO); err != nil {
+		writeServiceError(w, r, err, http.StatusForbidden, CodeForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", metrics.ContentType)
+	m.registry.WriteTo(w)
+}
+
+// routePattern returns the path of the mux pattern r matches, without its
+// method, or unmatchedRoute.
+func routePattern(mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	if pattern == "" {
+		return unmatchedRoute
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"revenue-intelligence-api/metrics"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	router := newTestRouter(t)
+	for _, path := range []string{APIPrefix + "/summary", APIPrefix + "/summary", APIPrefix + "/deals/D2/next-action", "/nope"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, MetricsPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != metrics.ContentType {
+		t.Errorf("Content-Type = %q, want %q", got, metrics.ContentType)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`revenue_api_http_requests_total{method="GET",route="/api/v1/summary",status="200"} 2` + "\n",
+		`revenue_api_http_requests_total{method="GET",route="/api/v1/deals/{deal_id}/next-action",status="200"} 1` + "\n",
+		`revenue_api_http_requests_total{method="GET",route="unmatched",status="404"} 1` + "\n",
+		`revenue_api_http_request_duration_seconds_bucket{method="GET",route="/api/v1/summary",le="+Inf"} 2` + "\n",
+		`revenue_api_http_request_duration_seconds_count{method="GET",route="/api/v1/summary"} 2` + "\n",
+		`revenue_api_http_requests_in_flight 1` + "\n",
+		`revenue_api_dataset_records{dataset="deals"} 600` + "\n",
+		`revenue_api_data_quality_violations{check="deal_missing_amount",severity="warning"} 290` + "\n",
+		`revenue_api_rules_loads_total{result="success"} 1` + "\n",
+		`revenue_api_quarter_amount{kind="revenue"} 743460` + "\n",
+		`revenue_api_quarter_amount{kind="gap"} -112605` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", strings.TrimSpace(want))
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"revenue-intelligence-api/models"
+	"revenue-intelligence-api/services"
+	"strings"
+)
+
+type apiParameter struct {
+	Name        string
+	In          string
+	Description string
+	Type        string
+	Enum        []string
+}
+
+type apiOperation struct {
+	Path       string
+	Method     string
+	Summary    string
+	Parameters []apiParameter
+	Request    interface{}
+	Response   interface{}
+}
+
+// apiOperations describes the public API for the OpenAPI document. Paths are
+// relative to APIPrefix. Response and Request hold zero values of the
+// response and request body types; their schemas are generated from the Go
+// types by reflection.
+var apiOperations = []apiOperation{
+	{Path: "/dashboard", Method: "get", Summary: "Summary, drivers, risk factors and recommendations from one snapshot", Response: models.DashboardResponse{}, Parameters: []apiParameter{
+		{Name: "window_days", Type: "integer", Description: "Closing window for the drivers' win_rate, in days up to the as-of date; 0 for all time"},
+	}},
+	{Path: "/summary", Method: "get", Summary: "Current quarter revenue summary", Response: models.SummaryResponse{}},
+	{Path: "/drivers", Method: "get", Summary: "Revenue driver metrics", Response: models.RevenueDrivers{}, Parameters: []apiParameter{
+		{Name: "window_days", Type: "integer", Description: "Closing window for win_rate, in days up to the as-of date; 0 for all time"},
+	}},
+	{Path: "/risk-factors", Method: "get", Summary: "Risk factors", Response: []models.RiskFactor{}},
+	{Path: "/risk-factors/{type}", Method: "get", Summary: "All matches of one risk type", Response: models.RiskFactorDetail{}, Parameters: []apiParameter{
+		{Name: "type", In: "path", Type: "string", Description: "Risk type, e.g. stale_deals"},
+		{Name: "sort", Type: "string", Description: "Risk item field or alias (amount, age, activity_count)"},
+		{Name: "order", Type: "string", Enum: []string{"asc", "desc"}},
+		{Name: "page", Type: "integer"},
+		{Name: "page_size", Type: "integer"},
+		{Name: "min_amount", Type: "number"},
+		{Name: "max_amount", Type: "number"},
+		{Name: "filter[field]", Type: "string", Description: "Match on a risk item field, e.g. filter[segment]=SMB; numbers match at the precision given"},
+	}},
+	{Path: "/recommendations", Method: "get", Summary: "Recommended actions", Response: []models.Recommendation{}},
+	{Path: "/recommendations/feedback", Method: "get", Summary: "Feedback recorded on recommendations", Response: []models.RecommendationFeedback{}},
+	{Path: "/recommendations/{id}/feedback", Method: "post", Summary: "Accept, dismiss or complete a recommendation", Request: models.RecommendationFeedbackRequest{}, Response: models.RecommendationFeedback{}, Parameters: []apiParameter{
+		{Name: "id", In: "path", Type: "string", Description: "Recommendation ID, e.g. coach_rep:R14"},
+	}},
+	{Path: "/recommendations/{id}/outcome", Method: "get", Summary: "Targeted metric now against its baseline when accepted", Response: models.RecommendationOutcome{}, Parameters: []apiParameter{
+		{Name: "id", In: "path", Type: "string"},
+	}},
+	{Path: "/deals/{deal_id}/next-action", Method: "get", Summary: "Suggested next activity for an open deal", Response: models.DealNextAction{}, Parameters: []apiParameter{
+		{Name: "deal_id", In: "path", Type: "string"},
+	}},
+	{Path: "/reps/{rep_id}/next-actions", Method: "get", Summary: "Most urgent next activities across a rep's open deals", Response: models.RepNextActions{}, Parameters: []apiParameter{
+		{Name: "rep_id", In: "path", Type: "string"},
+		{Name: "limit", Type: "integer", Description: "Number of deals to return, 1-100 (default 10)"},
+	}},
+	{Path: "/trends", Method: "get", Summary: "Historical trend series", Response: models.TrendResponse{}, Parameters: []apiParameter{
+		{Name: "metric", Type: "string", Enum: services.TrendMetrics},
+		{Name: "granularity", Type: "string", Enum: services.TrendGranularities},
+	}},
+	{Path: "/cohorts", Method: "get", Summary: "Deal cohorts by creation month", Response: models.CohortResponse{}, Parameters: []apiParameter{
+		{Name: "segment", Type: "string", Description: "Account segment filter"},
+	}},
+	{Path: "/activity-effectiveness", Method: "get", Summary: "Activity effectiveness by segment", Response: []models.ActivityEffectiveness{}},
+	{Path: "/risk-rules", Method: "get", Summary: "Loaded risk rules", Response: services.RuleSet{}},
+	{Path: "/risk-rules/reload", Method: "post", Summary: "Reload risk rules from disk", Response: services.RuleSet{}},
+	{Path: "/simulation", Method: "get", Summary: "Monte Carlo simulation of the current quarter", Response: models.SimulationResult{}, Parameters: []apiParameter{
+		{Name: "iterations", Type: "integer"},
+		{Name: "seed", Type: "integer"},
+	}},
+	{Path: "/openapi.json", Method: "get", Summary: "This document", Response: map[string]interface{}{}},
+}
+
+// unionVariants lists the concrete types of each interface used in a
+// response. Variants with a RiskKind method get a "kind" discriminator.
+var unionVariants = map[reflect.Type][]reflect.Type{
+	reflect.TypeOf((*models.RiskData)(nil)).Elem(): {
+		reflect.TypeOf(models.DealRiskData{}),
+		reflect.TypeOf(models.RepRiskData{}),
+		reflect.TypeOf(models.AccountRiskData{}),
+	},
+}
+
+func (h *Handlers) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, OpenAPIDocument())
+}
+
+// OpenAPIDocument builds an OpenAPI 3.1 document for the API. Component
+// schemas are JSON Schema (2020-12) generated from the models.
+func OpenAPIDocument() map[string]interface{} {
+	g := &schemaGenerator{components: make(map[string]interface{})}
+	errorResponse := map[string]interface{}{
+		"description": "Error",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": g.schemaFor(reflect.TypeOf(models.ErrorResponse{})),
+			},
+		},
+	}
+
+	paths := make(map[string]interface{})
+	for _, op := range apiOperations {
+		operation := map[string]interface{}{
+			"summary": op.Summary,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": g.schemaFor(reflect.TypeOf(op.Response)),
+						},
+					},
+				},
+				"default": errorResponse,
+			},
+		}
+
+		if publicPaths[op.Path] {
+			operation["security"] = []interface{}{}
+		}
+
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": g.schemaFor(reflect.TypeOf(op.Request)),
+					},
+				},
+			}
+		}
+
+		if len(op.Parameters) > 0 {
+			params := []interface{}{}
+			for _, p := range op.Parameters {
+				schema := map[string]interface{}{"type": p.Type}
+				if len(p.Enum) > 0 {
+					schema["enum"] = p.Enum
+				}
+				in := p.In
+				if in == "" {
+					in = "query"
+				}
+				param := map[string]interface{}{"name": p.Name, "in": in, "schema": schema}
+				if in == "path" {
+					param["required"] = true
+				}
+				if p.Description != "" {
+					param["description"] = p.Description
+				}
+				params = append(params, param)
+			}
+			operation["parameters"] = params
+		}
+
+		path := APIPrefix + op.Path
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+		item[op.Method] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi":           "3.1.0",
+		"jsonSchemaDialect": "https://json-schema.org/draft/2020-12/schema",
+		"info": map[string]interface{}{
+			"title":   "Revenue Intelligence API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		// Authentication is only enforced when it is configured.
+		"security": []interface{}{
+			map[string]interface{}{"bearerAuth": []string{}},
+			map[string]interface{}{"apiKey": []string{}},
+		},
+		"components": map[string]interface{}{
+			"schemas": g.components,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey":     map[string]interface{}{"type": "apiKey", "in": "header", "name": APIKeyHeader},
+			},
+		},
+	}
+}
+
+type schemaGenerator struct {
+	components map[string]interface{}
+}
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Pointer:
+		inner := g.schemaFor(t.Elem())
+		if typ, ok := inner["type"].(string); ok {
+			nullable := make(map[string]interface{}, len(inner))
+			for k, v := range inner {
+				nullable[k] = v
+			}
+			nullable["type"] = []string{typ, "null"}
+			return nullable
+		}
+		return map[string]interface{}{"oneOf": []interface{}{inner, map[string]interface{}{"type": "null"}}}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return g.unionSchema(t)
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+	return map[string]interface{}{}
+}
+
+func (g *schemaGenerator) unionSchema(t reflect.Type) map[string]interface{} {
+	variants, ok := unionVariants[t]
+	if !ok {
+		return map[string]interface{}{}
+	}
+
+	oneOf := []interface{}{}
+	mapping := make(map[string]interface{})
+	for _, v := range variants {
+		ref := g.structRef(v)
+		oneOf = append(oneOf, ref)
+		if data, ok := reflect.Zero(v).Interface().(models.RiskData); ok {
+			mapping[data.RiskKind()] = ref["$ref"]
+		}
+	}
+
+	schema := map[string]interface{}{"oneOf": oneOf}
+	if len(mapping) > 0 {
+		schema["discriminator"] = map[string]interface{}{"propertyName": "kind", "mapping": mapping}
+	}
+	return schema
+}
+
+// addFields adds the JSON properties of struct t, flattening embedded
+// structs the way encoding/json does.
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.addFields(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = g.schemaFor(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func (g *schemaGenerator) structRef(t reflect.Type) map[string]interface{} {
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	if _, ok := g.components[t.Name()]; ok {
+		return ref
+	}
+	// Register before recursing so self-referencing types terminate.
+	g.components[t.Name()] = nil
+
+	properties := make(map[string]interface{})
+	required := []string{}
+	g.addFields(t, properties, &required)
+
+	if data, ok := reflect.Zero(t).Interface().(models.RiskData); ok {
+		if _, hasKind := properties["kind"]; hasKind {
+			properties["kind"] = map[string]interface{}{"type": "string", "const": data.RiskKind()}
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	g.components[t.Name()] = schema
+	return ref
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIPrefix is the versioned path prefix. Every route is also served under
+// LegacyAPIPrefix for existing clients.
+const (
+	APIPrefix       = "/api/v1"
+	LegacyAPIPrefix = "/api"
+)
+
+// HealthPath and ReadyPath are the liveness and readiness probes. Like
+// MetricsPath they are served outside the API prefix, and without
+// authentication so orchestrators can call them.
+const (
+	HealthPath = "/healthz"
+	ReadyPath  = "/readyz"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+}
+
+type requestIDKey struct{}
+
+type RouterOptions struct {
+	// RequestTimeout cancels each request's context, if it is positive.
+	RequestTimeout time.Duration
+
+	CORS CORSPolicy
+
+	// Auth authenticates every route but publicPaths; nil disables it.
+	Auth *Authenticator
+
+	// Logger receives access logs and recovered panics; nil uses
+	// slog.Default().
+	Logger *slog.Logger
+}
+
+// Routes lists every endpoint, relative to the API prefix.
+func (h *Handlers) Routes() []Route {
+	return []Route{
+		{http.MethodGet, "/dashboard", h.GetDashboard},
+		{http.MethodGet, "/summary", h.GetSummary},
+		{http.MethodGet, "/drivers", h.GetDrivers},
+		{http.MethodGet, "/risk-factors", h.GetRiskFactors},
+		{http.MethodGet, "/risk-factors/{type}", h.GetRiskFactorDetail},
+		{http.MethodGet, "/recommendations", h.GetRecommendations},
+		{http.MethodGet, "/recommendations/feedback", h.GetRecommendationFeedback},
+		{http.MethodPost, "/recommendations/{id}/feedback", h.SetRecommendationFeedback},
+		{http.MethodGet, "/recommendations/{id}/outcome", h.GetRecommendationOutcome},
+		{http.MethodGet, "/deals/{deal_id}/next-action", h.GetDealNextAction},
+		{http.MethodGet, "/reps/{rep_id}/next-actions", h.GetRepNextActions},
+		{http.MethodGet, "/trends", h.GetTrends},
+		{http.MethodGet, "/cohorts", h.GetCohorts},
+		{http.MethodGet, "/activity-effectiveness", h.GetActivityEffectiveness},
+		{http.MethodGet, "/risk-rules", h.GetRiskRules},
+		{http.MethodPost, "/risk-rules/reload", h.ReloadRiskRules},
+		{http.MethodGet, "/simulation", h.GetSimulation},
+		{http.MethodGet, "/openapi.json", h.GetOpenAPI},
+	}
+}
+
+// NewRouter serves Routes under APIPrefix and LegacyAPIPrefix using method
+// patterns, authenticates them, tags GET responses with ETags (see
+// withETag), answers CORS preflight requests for every path with the
+// methods it serves, and writes the JSON error envelope for unknown paths
+// and methods. Every request gets a request ID and an access
+// log line and is counted in the metrics served at MetricsPath, and a panic
+// is answered with a 500.
+func NewRouter(h *Handlers, opts RouterOptions) http.Handler {
+	mux := http.NewServeMux()
+	cors := opts.CORS
+
+	paths := []string{}
+	methods := make(map[string][]string)
+	for _, route := range h.Routes() {
+		if _, ok := methods[route.Path]; !ok {
+			paths = append(paths, route.Path)
+		}
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+
+	for _, prefix := range []string{APIPrefix, LegacyAPIPrefix} {
+		for _, route := range h.Routes() {
+			handler := route.Handler
+			if route.Method == http.MethodGet {
+				handler = h.withETag(handler)
+			}
+			if !publicPaths[route.Path] {
+				handler = opts.Auth.Handle(handler)
+			}
+			mux.HandleFunc(route.Method+" "+prefix+route.Path, cors.Handle(handler))
+		}
+		for _, path := range paths {
+			mux.HandleFunc(http.MethodOptions+" "+prefix+path, cors.Preflight(methods[path]))
+		}
+	}
+
+	mux.HandleFunc(http.MethodGet+" "+HealthPath, h.GetHealth)
+	mux.HandleFunc(http.MethodGet+" "+ReadyPath, h.GetReadiness)
+
+	m := newHTTPMetrics(h)
+	mux.HandleFunc(http.MethodGet+" "+MetricsPath, opts.Auth.Handle(m.serve))
+
+	fallback := cors.Handle(func(w http.ResponseWriter, r *http.Request) {
+		// The mux has no JSON fallback, so tell a wrong method from an
+		// unknown path by retrying the lookup with each method.
+		allowed := []string{}
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			if _, pattern := mux.Handler(probe); pattern != "" {
+				allowed = append(allowed, method)
+			}
+		}
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+			writeError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed", map[string]interface{}{"allowed": allowed})
+			return
+		}
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "No route for "+r.URL.Path, nil)
+	})
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	route := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		fallback(w, r)
+	})
+	routeOf := func(r *http.Request) string { return routePattern(mux, r) }
+	return withRequestID(withAccessLog(logger, m.instrument(routeOf, withRecovery(logger, withTimeout(opts.RequestTimeout, route)))))
+}
@@ -1,39 +1,133 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"path/filepath"
+	"os"
+	"os/signal"
+	"revenue-intelligence-api/config"
 	"revenue-intelligence-api/handlers"
 	"revenue-intelligence-api/services"
+	"syscall"
+	"time"
 )
 
 func main() {
-	dataPath := filepath.Join("..", "data")
+	cfg, printConfig, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if printConfig {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(cfg)
+		return
+	}
+
+	logger := cfg.Logger(os.Stderr)
+	slog.SetDefault(logger)
+
+	dataService, err := services.NewDataService(cfg.DataPath)
+	if err != nil {
+		fatal("Failed to load data", err)
+	}
+	dataService.Calendar = cfg.Calendar
+
+	ruleEngine, err := services.NewRuleEngine(cfg.RulesPath)
+	if err != nil {
+		fatal("Failed to load risk rules", err)
+	}
 
-	dataService, err := services.NewDataService(dataPath)
+	feedbackStore, err := services.NewRecommendationStore(cfg.FeedbackPath)
 	if err != nil {
-		log.Fatalf("Failed to load data: %v", err)
+		fatal("Failed to load recommendation feedback", err)
+	}
+
+	analyticsService := services.NewAnalyticsService(dataService, ruleEngine, feedbackStore)
+	analyticsService.Thresholds = cfg.Thresholds
+	analyticsService.MaxDataAge = time.Duration(cfg.MaxDataAge)
+	simulationService := services.NewSimulationService(dataService)
+	simulationService.MinSegmentSample = cfg.Thresholds.MinSegmentSample
+	h := handlers.NewHandlers(analyticsService, simulationService)
+
+	auth := &handlers.Authenticator{
+		JWTSecret:   []byte(cfg.Auth.JWTSecret),
+		JWTIssuer:   cfg.Auth.JWTIssuer,
+		JWTAudience: cfg.Auth.JWTAudience,
+	}
+	if cfg.Auth.APIKeysPath != "" {
+		auth.APIKeys, err = handlers.LoadAPIKeys(cfg.Auth.APIKeysPath)
+		if err != nil {
+			fatal("Failed to load API keys", err)
+		}
+	}
+	if !cfg.Auth.Enabled() {
+		slog.Warn("Authentication is disabled; every request sees all data")
+	}
+
+	server := &http.Server{
+		Addr: cfg.ListenAddr,
+		Handler: handlers.NewRouter(h, handlers.RouterOptions{
+			RequestTimeout: time.Duration(cfg.Timeouts.Request),
+			CORS: handlers.CORSPolicy{
+				Origins:          cfg.CORSOrigins,
+				AllowCredentials: cfg.CORSAllowCredentials,
+				MaxAge:           time.Duration(cfg.CORSMaxAge),
+			},
+			Auth:   auth,
+			Logger: logger,
+		}),
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		ReadHeaderTimeout: time.Duration(cfg.Timeouts.ReadHeader),
+		ReadTimeout:       time.Duration(cfg.Timeouts.Read),
+		WriteTimeout:      time.Duration(cfg.Timeouts.Write),
+		IdleTimeout:       time.Duration(cfg.Timeouts.Idle),
 	}
 
-	analyticsService := services.NewAnalyticsService(dataService)
-	h := handlers.NewHandlers(analyticsService)
+	slog.Debug("Loaded configuration", "data_path", cfg.DataPath, "rules_path", cfg.RulesPath, "feedback_path", cfg.FeedbackPath)
+	for _, route := range h.Routes() {
+		slog.Debug("Route", "method", route.Method, "path", handlers.APIPrefix+route.Path, "legacy_path", handlers.LegacyAPIPrefix+route.Path)
+	}
+	slog.Info("Server starting", "addr", server.Addr, "routes", len(h.Routes()), "auth", cfg.Auth.Enabled())
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	http.HandleFunc("/api/summary", handlers.EnableCORS(h.GetSummary))
-	http.HandleFunc("/api/drivers", handlers.EnableCORS(h.GetDrivers))
-	http.HandleFunc("/api/risk-factors", handlers.EnableCORS(h.GetRiskFactors))
-	http.HandleFunc("/api/recommendations", handlers.EnableCORS(h.GetRecommendations))
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
 
-	port := "8080"
-	fmt.Printf("Server starting on port %s...\n", port)
-	fmt.Println("Endpoints available:")
-	fmt.Println("  GET /api/summary")
-	fmt.Println("  GET /api/drivers")
-	fmt.Println("  GET /api/risk-factors")
-	fmt.Println("  GET /api/recommendations")
+	select {
+	case err := <-serveErr:
+		fatal("Server failed to start", err)
+	case <-ctx.Done():
+	}
+	// Restore default signal handling so a second signal exits at once.
+	stop()
 
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	shutdownTimeout := time.Duration(cfg.Timeouts.Shutdown)
+	slog.Info("Shutting down, draining requests", "timeout", shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Shutdown did not complete", "error", err)
 	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Server error", "error", err)
+	}
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,301 @@
+// Package metrics implements the subset of Prometheus metric types the
+// server exposes, written in the Prometheus text exposition format (0.0.4)
+// without a client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are latency buckets in seconds, as in the Prometheus
+// client libraries.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Sample is one value of a metric, with its label values in the order of
+// the metric's label names.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics and writes them in registration order.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo writes every metric in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := append([]collector{}, r.collectors...)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// writeSample writes name{labels} value, where extra is a label appended
+// after the metric's own, such as a histogram's le.
+func (d desc) writeSample(w *bufio.Writer, name string, labelValues []string, extra []string, value float64) {
+	w.WriteString(name)
+	pairs := make([]string, 0, len(d.labels)+1)
+	for i, label := range d.labels {
+		pairs = append(pairs, label+`="`+escapeLabel(labelValues[i])+`"`)
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeLabel(extra[1])+`"`)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+// vec stores one value per combination of label values.
+type vec[T any] struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*T
+	keys   map[string][]string
+}
+
+func newVec[T any](d desc) vec[T] {
+	return vec[T]{desc: d, values: make(map[string]*T), keys: make(map[string][]string)}
+}
+
+// with returns the value for labelValues, creating it. The caller holds mu.
+func (v *vec[T]) with(labelValues []string) *T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	value, ok := v.values[key]
+	if !ok {
+		value = new(T)
+		v.values[key] = value
+		v.keys[key] = append([]string{}, labelValues...)
+	}
+	return value
+}
+
+func (v *vec[T]) sortedKeys() []string {
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec is a monotonically increasing count per label values.
+type CounterVec struct {
+	vec[float64]
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec[float64](desc{name, help, "counter", labels})}
+	r.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.with(labelValues) += delta
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w)
+	for _, key := range c.sortedKeys() {
+		c.writeSample(w, c.name, c.keys[key], nil, *c.values[key])
+	}
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	desc
+	mu    sync.Mutex
+	value float64
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{desc: desc{name: name, help: help, kind: "gauge"}}
+	r.register(g)
+	return g
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value += delta
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(w)
+	g.writeSample(w, g.name, nil, nil, g.value)
+}
+
+// HistogramVec counts observations into cumulative buckets per label values.
+type HistogramVec struct {
+	vec[histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{vec: newVec[histogram](desc{name, help, "histogram", labels}), buckets: buckets}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist := h.with(labelValues)
+	if hist.counts == nil {
+		hist.counts = make([]uint64, len(h.buckets))
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, key := range h.sortedKeys() {
+		labelValues, hist := h.keys[key], h.values[key]
+		for i, bound := range h.buckets {
+			h.writeSample(w, h.name+"_bucket", labelValues, []string{"le", formatFloat(bound)}, float64(hist.counts[i]))
+		}
+		h.writeSample(w, h.name+"_bucket", labelValues, []string{"le", "+Inf"}, float64(hist.count))
+		h.writeSample(w, h.name+"_sum", labelValues, nil, hist.sum)
+		h.writeSample(w, h.name+"_count", labelValues, nil, float64(hist.count))
+	}
+}
+
+// GaugeFunc is a gauge whose samples are computed by fn on every scrape.
+type GaugeFunc struct {
+	desc
+	fn func() []Sample
+}
+
+// NewGaugeFunc registers a gauge computed at scrape time. fn returns one
+// sample per label value combination; with no labels, a single sample.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name, help, "gauge", labels}, fn: fn}
+	r.register(g)
+	return g
+}
+
+// CounterFunc is a counter whose samples are computed by fn on every
+// scrape, for counts another package already keeps.
+type CounterFunc struct {
+	GaugeFunc
+}
+
+func (r *Registry) NewCounterFunc(name, help string, fn func() []Sample, labels ...string) *CounterFunc {
+	c := &CounterFunc{GaugeFunc{desc: desc{name, help, "counter", labels}, fn: fn}}
+	r.register(c)
+	return c
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	samples := g.fn()
+	if len(samples) == 0 {
+		return
+	}
+	g.writeHeader(w)
+	for _, s := range samples {
+		g.writeSample(w, g.name, s.LabelValues, nil, s.Value)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(n) != b.Len() {
+		t.Errorf("WriteTo returned %d bytes, wrote %d", n, b.Len())
+	}
+	return b.String()
+}
+
+func assertText(t *testing.T, got, want string) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("requests_total", "Requests served.", "method", "status")
+	c.Inc("GET", "200")
+	c.Inc("GET", "200")
+	c.Add(2.5, "POST", "400")
+	c.Inc("GET", "404")
+
+	assertText(t, render(t, r), `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{method="GET",status="200"} 2
+requests_total{method="GET",status="404"} 1
+requests_total{method="POST",status="400"} 2.5
+`)
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(3, "/a")
+
+	assertText(t, render(t, r), `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 1
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 3.55
+latency_seconds_count{route="/a"} 3
+`)
+}
+
+func TestGauge(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("in_flight", "In flight.")
+	g.Add(2)
+	g.Add(-1)
+
+	assertText(t, render(t, r), `# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 1
+`)
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	value := 1.0
+	r.NewGaugeFunc("records", "Records per dataset.", func() []Sample {
+		return []Sample{
+			{LabelValues: []string{"deals"}, Value: value},
+			{LabelValues: []string{"reps"}, Value: 15},
+		}
+	}, "dataset")
+	r.NewGaugeFunc("load_seconds", "Load time.", func() []Sample {
+		return []Sample{{Value: 0.25}}
+	})
+	r.NewGaugeFunc("unavailable", "Skipped when there are no samples.", func() []Sample {
+		return nil
+	})
+
+	value = 600
+	assertText(t, render(t, r), `# HELP records Records per dataset.
+# TYPE records gauge
+records{dataset="deals"} 600
+records{dataset="reps"} 15
+# HELP load_seconds Load time.
+# TYPE load_seconds gauge
+load_seconds 0.25
+`)
+}
+
+func TestCounterFunc(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterFunc("loads_total", "Loads.", func() []Sample {
+		return []Sample{{LabelValues: []string{"success"}, Value: 3}}
+	}, "result")
+
+	assertText(t, render(t, r), `# HELP loads_total Loads.
+# TYPE loads_total counter
+loads_total{result="success"} 3
+`)
+}
+
+func TestEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("escaped_total", "Help with \\ and\nnewline.", "value")
+	c.Inc("quote \" backslash \\ newline \n end")
+
+	assertText(t, render(t, r), `# HELP escaped_total Help with \\ and\nnewline.
+# TYPE escaped_total counter
+escaped_total{value="quote \" backslash \\ newline \n end"} 1
+`)
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := map[float64]string{
+		0:           "0",
+		1e9:         "1e+09",
+		0.005:       "0.005",
+		-112605:     "-112605",
+		6.365441e06: "6.365441e+06",
+	}
+	for v, want := range tests {
+		if got := formatFloat(v); got != want {
+			t.Errorf("formatFloat(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
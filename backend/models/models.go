@@ -13,13 +13,14 @@ type Rep struct {
 }
 
 type Deal struct {
-	DealID    string     `json:"deal_id"`
-	AccountID string     `json:"account_id"`
-	RepID     string     `json:"rep_id"`
-	Stage     string     `json:"stage"`
-	Amount    *float64   `json:"amount"`
-	CreatedAt string     `json:"created_at"`
-	ClosedAt  *string    `json:"closed_at"`
+	DealID         string   `json:"deal_id"`
+	AccountID      string   `json:"account_id"`
+	RepID          string   `json:"rep_id"`
+	Stage          string   `json:"stage"`
+	Amount         *float64 `json:"amount"`
+	CreatedAt      string   `json:"created_at"`
+	ClosedAt       *string  `json:"closed_at"`
+	StageChangedAt *string  `json:"stage_changed_at,omitempty"`
 }
 
 type Activity struct {
@@ -35,33 +36,373 @@ type Target struct {
 }
 
 type SummaryResponse struct {
-	CurrentQuarter       int     `json:"current_quarter"`
-	CurrentQuarterYear   int     `json:"current_quarter_year"`
-	Revenue              float64 `json:"revenue"`
-	Target               float64 `json:"target"`
-	Gap                  float64 `json:"gap"`
-	GapPercentage        float64 `json:"gap_percentage"`
-	QoQChange            float64 `json:"qoq_change"`
-	QoQChangePercentage  float64 `json:"qoq_change_percentage"`
+	CurrentQuarter      int     `json:"current_quarter"`
+	CurrentQuarterYear  int     `json:"current_quarter_year"`
+	Revenue             float64 `json:"revenue"`
+	Target              float64 `json:"target"`
+	Gap                 float64 `json:"gap"`
+	GapPercentage       float64 `json:"gap_percentage"`
+	QoQChange           float64 `json:"qoq_change"`
+	QoQChangePercentage float64 `json:"qoq_change_percentage"`
 }
 
 type RevenueDrivers struct {
 	PipelineSize      float64 `json:"pipeline_size"`
 	WinRate           float64 `json:"win_rate"`
+	WinRateWindowDays int     `json:"win_rate_window_days"`
+	ClosedDeals       int     `json:"closed_deals"`
+	AllDealWinRate    float64 `json:"all_deal_win_rate"`
 	AverageDealSize   float64 `json:"average_deal_size"`
 	SalesCycleTime    float64 `json:"sales_cycle_time"`
 }
 
 type RiskFactor struct {
-	Type        string      `json:"type"`
-	Description string      `json:"description"`
-	Severity    string      `json:"severity"`
-	Data        interface{} `json:"data"`
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Severity    string   `json:"severity"`
+	Score       float64  `json:"score"`
+	ValueAtRisk float64  `json:"value_at_risk"`
+	Data        RiskData `json:"data"`
 }
 
+const (
+	RiskKindDeal    = "deal"
+	RiskKindRep     = "rep"
+	RiskKindAccount = "account"
+)
+
+// RiskData is the payload of a RiskFactor. Its "kind" field says which of
+// DealRiskData, RepRiskData or AccountRiskData it is.
+type RiskData interface {
+	RiskKind() string
+}
+
+type DealRiskItem struct {
+	DealID            string   `json:"deal_id"`
+	AccountID         string   `json:"account_id"`
+	AccountName       string   `json:"account_name"`
+	RepID             string   `json:"rep_id"`
+	RepName           string   `json:"rep_name"`
+	Stage             string   `json:"stage"`
+	Amount            *float64 `json:"amount"`
+	IsOpen            bool     `json:"is_open"`
+	Segment           string   `json:"segment"`
+	Industry          string   `json:"industry"`
+	AgeDays           *int     `json:"age_days"`
+	ActivityCount     int      `json:"activity_count"`
+	DaysSinceActivity *int     `json:"days_since_activity"`
+	LastActivityDate  *string  `json:"last_activity_date"`
+	LastActivityType  *string  `json:"last_activity_type"`
+	DaysInStage       *int     `json:"days_in_stage"`
+}
+
+type RepRiskItem struct {
+	RepID               string  `json:"rep_id"`
+	RepName             string  `json:"rep_name"`
+	TotalDeals          int     `json:"total_deals"`
+	WonDeals            int     `json:"won_deals"`
+	LostDeals           int     `json:"lost_deals"`
+	ClosedDeals         int     `json:"closed_deals"`
+	OpenDeals           int     `json:"open_deals"`
+	WinRate             float64 `json:"win_rate"`
+	WinRateLower        float64 `json:"win_rate_lower"`
+	WinRateUpper        float64 `json:"win_rate_upper"`
+	TeamWinRate         float64 `json:"team_win_rate"`
+	ExpectedRevenueLost float64 `json:"expected_revenue_lost"`
+	OpenPipeline        float64 `json:"open_pipeline"`
+}
+
+type AccountRiskItem struct {
+	AccountID       string  `json:"account_id"`
+	AccountName     string  `json:"account_name"`
+	Segment         string  `json:"segment"`
+	Industry        string  `json:"industry"`
+	OpenDeals       int     `json:"open_deals"`
+	TotalActivities int     `json:"total_activities"`
+	AvgActivities   float64 `json:"avg_activities"`
+	OpenPipeline    float64 `json:"open_pipeline"`
+}
+
+type DealRiskData struct {
+	Kind  string         `json:"kind"`
+	Count int            `json:"count"`
+	Deals []DealRiskItem `json:"deals"`
+}
+
+type RepRiskData struct {
+	Kind  string        `json:"kind"`
+	Count int           `json:"count"`
+	Reps  []RepRiskItem `json:"reps"`
+}
+
+type AccountRiskData struct {
+	Kind     string            `json:"kind"`
+	Count    int               `json:"count"`
+	Accounts []AccountRiskItem `json:"accounts"`
+}
+
+func (DealRiskData) RiskKind() string    { return RiskKindDeal }
+func (RepRiskData) RiskKind() string     { return RiskKindRep }
+func (AccountRiskData) RiskKind() string { return RiskKindAccount }
+
 type Recommendation struct {
-	Priority    string `json:"priority"`
-	Action      string `json:"action"`
-	Impact      string `json:"impact"`
-	Description string `json:"description"`
+	ID           string                 `json:"id"`
+	Type         string                 `json:"type"`
+	Status       string                 `json:"status"`
+	Priority     string                 `json:"priority"`
+	Action       string                 `json:"action"`
+	Impact       string                 `json:"impact"`
+	Description  string                 `json:"description"`
+	ImpactAmount float64                `json:"impact_amount"`
+	GapShare     float64                `json:"gap_share"`
+	Confidence   float64                `json:"confidence"`
+	Score        float64                `json:"score"`
+	Evidence     RecommendationEvidence `json:"evidence"`
+}
+
+type RecommendationEvidence struct {
+	Segment string   `json:"segment,omitempty"`
+	DealIDs []string `json:"deal_ids"`
+	RepIDs  []string `json:"rep_ids"`
+}
+
+const (
+	RecommendationOpen      = "open"
+	RecommendationAccepted  = "accepted"
+	RecommendationDismissed = "dismissed"
+	RecommendationDone      = "done"
+)
+
+type RecommendationFeedbackRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+type RecommendationStatusChange struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+	At     string `json:"at"`
+}
+
+// RecommendationFeedback is the persisted state of one recommendation. The
+// baseline is the value of the targeted metric when the recommendation was
+// first accepted (or marked done without being accepted).
+type RecommendationFeedback struct {
+	RecommendationID string                       `json:"recommendation_id"`
+	Type             string                       `json:"type"`
+	Action           string                       `json:"action"`
+	Status           string                       `json:"status"`
+	Note             string                       `json:"note"`
+	UpdatedAt        string                       `json:"updated_at"`
+	Evidence         RecommendationEvidence       `json:"evidence"`
+	Metric           string                       `json:"metric"`
+	Baseline         *float64                     `json:"baseline"`
+	BaselineAt       *string                      `json:"baseline_at"`
+	History          []RecommendationStatusChange `json:"history"`
+}
+
+type RecommendationOutcome struct {
+	RecommendationID string  `json:"recommendation_id"`
+	Type             string  `json:"type"`
+	Status           string  `json:"status"`
+	Metric           string  `json:"metric"`
+	HigherIsBetter   bool    `json:"higher_is_better"`
+	Baseline         float64 `json:"baseline"`
+	BaselineAt       string  `json:"baseline_at"`
+	Current          float64 `json:"current"`
+	Change           float64 `json:"change"`
+	Improved         bool    `json:"improved"`
+}
+
+// ActivityNorms are the activity patterns of closed deals in a segment, used
+// as the reference for a deal's next action.
+type ActivityNorms struct {
+	Segment                     string             `json:"segment"`
+	ClosedDeals                 int                `json:"closed_deals"`
+	MedianDaysBetweenActivities float64            `json:"median_days_between_activities"`
+	MedianActivitiesBeforeClose float64            `json:"median_activities_before_close"`
+	WinRateWithDemo             float64            `json:"win_rate_with_demo"`
+	WinRateWithoutDemo          float64            `json:"win_rate_without_demo"`
+	AvgPerWonDeal               map[string]float64 `json:"avg_per_won_deal"`
+}
+
+type DealNextAction struct {
+	Deal           DealRiskItem   `json:"deal"`
+	ActivityCounts map[string]int `json:"activity_counts"`
+	Activity       string         `json:"activity"`
+	Priority       string         `json:"priority"`
+	Reason         string         `json:"reason"`
+	DueInDays      int            `json:"due_in_days"`
+	Norms          ActivityNorms  `json:"norms"`
+}
+
+type RepNextActions struct {
+	RepID     string           `json:"rep_id"`
+	RepName   string           `json:"rep_name"`
+	OpenDeals int              `json:"open_deals"`
+	Actions   []DealNextAction `json:"actions"`
+}
+
+type SimulationResult struct {
+	CurrentQuarter     int     `json:"current_quarter"`
+	CurrentQuarterYear int     `json:"current_quarter_year"`
+	Iterations         int     `json:"iterations"`
+	Seed               int64   `json:"seed"`
+	BookedRevenue      float64 `json:"booked_revenue"`
+	Target             float64 `json:"target"`
+	OpenDealsSimulated int     `json:"open_deals_simulated"`
+	// QuarterEnded is set when the current quarter is over, so the outcome
+	// is the booked revenue and no open deals are simulated.
+	QuarterEnded        bool    `json:"quarter_ended"`
+	ProbabilityOfTarget float64 `json:"probability_of_target"`
+	MeanRevenue         float64 `json:"mean_revenue"`
+	P10Revenue          float64 `json:"p10_revenue"`
+	P50Revenue          float64 `json:"p50_revenue"`
+	P90Revenue          float64 `json:"p90_revenue"`
+}
+
+type TrendPoint struct {
+	Period    string  `json:"period"`
+	StartDate string  `json:"start_date"`
+	EndDate   string  `json:"end_date"`
+	Value     float64 `json:"value"`
+	Count     int     `json:"count"`
+}
+
+type TrendResponse struct {
+	Metric       string       `json:"metric"`
+	Granularity  string       `json:"granularity"`
+	Series       []TrendPoint `json:"series"`
+	TargetSeries []TrendPoint `json:"target_series,omitempty"`
+}
+
+type CohortMilestone struct {
+	Days           int     `json:"days"`
+	Matured        bool    `json:"matured"`
+	WonDeals       int     `json:"won_deals"`
+	LostDeals      int     `json:"lost_deals"`
+	WonAmount      float64 `json:"won_amount"`
+	WinRate        float64 `json:"win_rate"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+type Cohort struct {
+	CohortMonth string            `json:"cohort_month"`
+	Deals       int               `json:"deals"`
+	TotalAmount float64           `json:"total_amount"`
+	Milestones  []CohortMilestone `json:"milestones"`
+}
+
+type CohortResponse struct {
+	Segment  string   `json:"segment,omitempty"`
+	AsOfDate string   `json:"as_of_date"`
+	Cohorts  []Cohort `json:"cohorts"`
+}
+
+type ActivityTypeStats struct {
+	Type            string  `json:"type"`
+	DealsWithType   int     `json:"deals_with_type"`
+	WinRateWithType float64 `json:"win_rate_with_type"`
+	AvgPerWonDeal   float64 `json:"avg_per_won_deal"`
+	AvgPerLostDeal  float64 `json:"avg_per_lost_deal"`
+}
+
+type ActivityEffectiveness struct {
+	Segment                     string              `json:"segment"`
+	ClosedDeals                 int                 `json:"closed_deals"`
+	WinRate                     float64             `json:"win_rate"`
+	DealsWithDemo               int                 `json:"deals_with_demo"`
+	WinRateWithDemo             float64             `json:"win_rate_with_demo"`
+	DealsWithoutDemo            int                 `json:"deals_without_demo"`
+	WinRateWithoutDemo          float64             `json:"win_rate_without_demo"`
+	DemoWinRateLift             float64             `json:"demo_win_rate_lift"`
+	MedianActivitiesBeforeClose float64             `json:"median_activities_before_close"`
+	MedianDaysFirstDemoToClose  float64             `json:"median_days_first_demo_to_close"`
+	MedianDaysBetweenActivities float64             `json:"median_days_between_activities"`
+	ActivityTypes               []ActivityTypeStats `json:"activity_types"`
+}
+
+type RiskFactorDetail struct {
+	RiskFactor
+	MatchCount int    `json:"match_count"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	TotalPages int    `json:"total_pages"`
+	Sort       string `json:"sort"`
+	Order      string `json:"order"`
+}
+
+// APIError is the body of every error response, wrapped in ErrorResponse.
+// Details carries machine-readable context such as the offending parameter.
+type APIError struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	RequestID string                 `json:"request_id"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+const (
+	RoleCRO     = "cro"
+	RoleManager = "manager"
+	RoleRep     = "rep"
+)
+
+// Principal is an authenticated caller. A CRO sees every rep; a manager sees
+// the reps in RepIDs, their team; a rep sees their own, the one rep in
+// RepIDs.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Role    string   `json:"role"`
+	RepIDs  []string `json:"rep_ids,omitempty"`
+}
+
+// DataQualityCheck is the result of one check of the loaded dataset.
+// Checks with error severity make the dataset invalid; warnings flag records
+// the analytics tolerate, such as deals without an amount.
+type DataQualityCheck struct {
+	Check       string   `json:"check"`
+	Entity      string   `json:"entity"`
+	Severity    string   `json:"severity"`
+	Description string   `json:"description"`
+	Violations  int      `json:"violations"`
+	Examples    []string `json:"examples"`
+}
+
+// ReadinessCheck is one condition the service must meet to take traffic.
+type ReadinessCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// Readiness reports whether the service can answer requests and how fresh
+// its data and risk rules are. Times are RFC 3339.
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+
+	DataLoadedAt   string  `json:"data_loaded_at"`
+	DataAgeSeconds float64 `json:"data_age_seconds"`
+	// DataStale is informational: the data is older than max_data_age.
+	DataStale bool `json:"data_stale"`
+
+	RulesLoadedAt      string  `json:"rules_loaded_at"`
+	RulesAgeSeconds    float64 `json:"rules_age_seconds"`
+	RuleReloadFailures int     `json:"rule_reload_failures"`
+}
+
+// DashboardResponse holds the summary, drivers, risk factors and
+// recommendations computed from one snapshot of the data, rules and feedback
+// at one as-of date.
+type DashboardResponse struct {
+	AsOfDate        string           `json:"as_of_date"`
+	Summary         SummaryResponse  `json:"summary"`
+	Drivers         RevenueDrivers   `json:"drivers"`
+	RiskFactors     []RiskFactor     `json:"risk_factors"`
+	Recommendations []Recommendation `json:"recommendations"`
 }
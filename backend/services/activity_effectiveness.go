@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"revenue-intelligence-api/models"
+	"sort"
+	"time"
+)
+
+// AllSegments labels aggregates computed across every segment.
+const AllSegments = "All"
+
+type closedDealActivity struct {
+	Segment         string
+	Won             bool
+	ActivityCounts  map[string]int
+	TotalActivities int
+	DaysFromDemo    *int
+	Gaps            []int
+}
+
+// GetActivityEffectiveness relates activity mix and cadence to outcomes for
+// closed deals, overall and per segment. Only activities on or before a
+// deal's close date are counted. The demo lift is the relative difference in
+// win rate between closed deals with and without a demo, as a percentage.
+func (as *AnalyticsService) GetActivityEffectiveness(ctx context.Context) ([]models.ActivityEffectiveness, error) {
+	return cached(ctx, as, "activity-effectiveness", as.activityEffectiveness)
+}
+
+func (as *AnalyticsService) activityEffectiveness(ctx context.Context) ([]models.ActivityEffectiveness, error) {
+	as = as.scoped(ctx)
+	closed, err := as.collectClosedDealActivity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []models.ActivityEffectiveness{summarizeActivity(AllSegments, closed)}
+	for _, segment := range as.DataService.GetSegments() {
+		segmentDeals := []closedDealActivity{}
+		for _, deal := range closed {
+			if deal.Segment == segment {
+				segmentDeals = append(segmentDeals, deal)
+			}
+		}
+		results = append(results, summarizeActivity(segment, segmentDeals))
+	}
+
+	return results, nil
+}
+
+func (as *AnalyticsService) collectClosedDealActivity(ctx context.Context) ([]closedDealActivity, error) {
+	closed := []closedDealActivity{}
+
+	for _, deal := range as.DataService.Deals {
+		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
+			continue
+		}
+
+		var closedAt *time.Time
+		if deal.ClosedAt != nil && *deal.ClosedAt != "" {
+			if t, err := as.DataService.ParseDate(*deal.ClosedAt); err == nil {
+				closedAt = &t
+			}
+		}
+
+		info := closedDealActivity{
+			Won:            deal.Stage == "Closed Won",
+			ActivityCounts: make(map[string]int),
+		}
+		if account := as.DataService.GetAccountByID(deal.AccountID); account != nil {
+			info.Segment = account.Segment
+		}
+
+		timestamps := []time.Time{}
+		var firstDemo *time.Time
+		activities, err := as.DataService.GetActivitiesByDealID(ctx, deal.DealID)
+		if err != nil {
+			return nil, err
+		}
+		for _, activity := range activities {
+			ts, err := as.DataService.ParseDate(activity.Timestamp)
+			if err != nil {
+				continue
+			}
+			if closedAt != nil && ts.After(*closedAt) {
+				continue
+			}
+			info.ActivityCounts[activity.Type]++
+			info.TotalActivities++
+			timestamps = append(timestamps, ts)
+			if activity.Type == "demo" && (firstDemo == nil || ts.Before(*firstDemo)) {
+				demo := ts
+				firstDemo = &demo
+			}
+		}
+
+		sort.Slice(timestamps, func(i, j int) bool { return timestamps[i].Before(timestamps[j]) })
+		for i := 1; i < len(timestamps); i++ {
+			info.Gaps = append(info.Gaps, int(timestamps[i].Sub(timestamps[i-1]).Hours()/24))
+		}
+
+		if info.Won && firstDemo != nil && closedAt != nil {
+			days := int(closedAt.Sub(*firstDemo).Hours() / 24)
+			info.DaysFromDemo = &days
+		}
+
+		closed = append(closed, info)
+	}
+
+	return closed, nil
+}
+
+func summarizeActivity(segment string, deals []closedDealActivity) models.ActivityEffectiveness {
+	result := models.ActivityEffectiveness{
+		Segment:       segment,
+		ClosedDeals:   len(deals),
+		ActivityTypes: []models.ActivityTypeStats{},
+	}
+
+	won, withDemo, wonWithDemo, wonWithoutDemo := 0, 0, 0, 0
+	wonActivityCounts := []float64{}
+	demoToClose := []float64{}
+	gaps := []float64{}
+	types := make(map[string]bool)
+
+	for _, deal := range deals {
+		hasDemo := deal.ActivityCounts["demo"] > 0
+		if hasDemo {
+			withDemo++
+		}
+		if deal.Won {
+			won++
+			if hasDemo {
+				wonWithDemo++
+			} else {
+				wonWithoutDemo++
+			}
+			wonActivityCounts = append(wonActivityCounts, float64(deal.TotalActivities))
+			if deal.DaysFromDemo != nil {
+				demoToClose = append(demoToClose, float64(*deal.DaysFromDemo))
+			}
+		}
+		for _, gap := range deal.Gaps {
+			gaps = append(gaps, float64(gap))
+		}
+		for activityType := range deal.ActivityCounts {
+			types[activityType] = true
+		}
+	}
+
+	result.WinRate = percentage(won, len(deals))
+	result.DealsWithDemo = withDemo
+	result.WinRateWithDemo = percentage(wonWithDemo, withDemo)
+	result.DealsWithoutDemo = len(deals) - withDemo
+	result.WinRateWithoutDemo = percentage(wonWithoutDemo, len(deals)-withDemo)
+	if result.WinRateWithoutDemo > 0 {
+		result.DemoWinRateLift = ((result.WinRateWithDemo - result.WinRateWithoutDemo) / result.WinRateWithoutDemo) * 100
+	}
+	result.MedianActivitiesBeforeClose = median(wonActivityCounts)
+	result.MedianDaysFirstDemoToClose = median(demoToClose)
+	result.MedianDaysBetweenActivities = median(gaps)
+
+	typeNames := make([]string, 0, len(types))
+	for activityType := range types {
+		typeNames = append(typeNames, activityType)
+	}
+	sort.Strings(typeNames)
+
+	for _, activityType := range typeNames {
+		stats := models.ActivityTypeStats{Type: activityType}
+		wonWithType, wonTotal, lostTotal := 0, 0, 0
+		for _, deal := range deals {
+			count := deal.ActivityCounts[activityType]
+			if count > 0 {
+				stats.DealsWithType++
+				if deal.Won {
+					wonWithType++
+				}
+			}
+			if deal.Won {
+				wonTotal += count
+			} else {
+				lostTotal += count
+			}
+		}
+		stats.WinRateWithType = percentage(wonWithType, stats.DealsWithType)
+		if won > 0 {
+			stats.AvgPerWonDeal = float64(wonTotal) / float64(won)
+		}
+		if lost := len(deals) - won; lost > 0 {
+			stats.AvgPerLostDeal = float64(lostTotal) / float64(lost)
+		}
+		result.ActivityTypes = append(result.ActivityTypes, stats)
+	}
+
+	return result
+}
+
+func percentage(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return (float64(part) / float64(whole)) * 100
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -1,24 +1,71 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"revenue-intelligence-api/models"
+	"sort"
 	"time"
 )
 
 type AnalyticsService struct {
 	DataService *DataService
+	RuleEngine  *RuleEngine
+	Feedback    *RecommendationStore
+	Thresholds  Thresholds
+
+	// MaxDataAge is how long after loading the data readiness reports it
+	// as stale; 0 means data never goes stale.
+	MaxDataAge time.Duration
+
+	cache *resultCache
+}
+
+// Thresholds are the tunable cut-offs of the analytics.
+type Thresholds struct {
+	// MinSegmentSample is the number of closed deals below which a segment
+	// falls back to the figures of all deals.
+	MinSegmentSample int `json:"min_segment_sample"`
+
+	// PipelineCoverageTarget is the ratio of open pipeline to next
+	// quarter's target considered healthy.
+	PipelineCoverageTarget float64 `json:"pipeline_coverage_target"`
+
+	// LowActivityPerDeal is the average activities per open deal below
+	// which a segment is recommended more activity.
+	LowActivityPerDeal float64 `json:"low_activity_per_deal"`
+
+	MaxRecommendations int `json:"max_recommendations"`
 }
 
-func NewAnalyticsService(ds *DataService) *AnalyticsService {
+var DefaultThresholds = Thresholds{
+	MinSegmentSample:       10,
+	PipelineCoverageTarget: 3.0,
+	LowActivityPerDeal:     3.0,
+	MaxRecommendations:     5,
+}
+
+func NewAnalyticsService(ds *DataService, re *RuleEngine, fs *RecommendationStore) *AnalyticsService {
 	return &AnalyticsService{
 		DataService: ds,
+		RuleEngine:  re,
+		Feedback:    fs,
+		Thresholds:  DefaultThresholds,
+		cache:       newResultCache(),
 	}
 }
 
-func (as *AnalyticsService) GetSummary() models.SummaryResponse {
+func (as *AnalyticsService) GetSummary(ctx context.Context) (models.SummaryResponse, error) {
+	return cached(ctx, as, "summary", as.summary)
+}
+
+func (as *AnalyticsService) summary(ctx context.Context) (models.SummaryResponse, error) {
+	as = as.scoped(ctx)
 	quarter, year := as.DataService.GetCurrentQuarter()
-	currentRevenue := as.DataService.GetQuarterRevenue(quarter, year)
+	currentRevenue, err := as.DataService.GetQuarterRevenue(ctx, quarter, year)
+	if err != nil {
+		return models.SummaryResponse{}, err
+	}
 	currentTarget := as.DataService.GetQuarterTarget(quarter, year)
 	gap := currentTarget - currentRevenue
 	gapPercentage := 0.0
@@ -33,7 +80,10 @@ func (as *AnalyticsService) GetSummary() models.SummaryResponse {
 		prevYear--
 	}
 
-	prevRevenue := as.DataService.GetQuarterRevenue(prevQuarter, prevYear)
+	prevRevenue, err := as.DataService.GetQuarterRevenue(ctx, prevQuarter, prevYear)
+	if err != nil {
+		return models.SummaryResponse{}, err
+	}
 	qoqChange := currentRevenue - prevRevenue
 	qoqChangePercentage := 0.0
 	if prevRevenue > 0 {
@@ -49,11 +99,26 @@ func (as *AnalyticsService) GetSummary() models.SummaryResponse {
 		GapPercentage:       gapPercentage,
 		QoQChange:           qoqChange,
 		QoQChangePercentage: qoqChangePercentage,
-	}
+	}, nil
 }
 
-func (as *AnalyticsService) GetRevenueDrivers() models.RevenueDrivers {
-	openDeals := as.DataService.GetOpenDeals()
+// GetRevenueDrivers reports pipeline, win rate, deal size and cycle time.
+// WinRate is won / (won + lost) over deals closed in the windowDays days up
+// to GetAsOfDate, or over all closed deals when windowDays is 0; closed deals
+// without a close date only count toward the all-time rate. AllDealWinRate
+// keeps the original definition, won / all deals including open ones.
+func (as *AnalyticsService) GetRevenueDrivers(ctx context.Context, windowDays int) (models.RevenueDrivers, error) {
+	return cached(ctx, as, fmt.Sprintf("drivers?window_days=%d", windowDays), func(ctx context.Context) (models.RevenueDrivers, error) {
+		return as.revenueDrivers(ctx, windowDays)
+	})
+}
+
+func (as *AnalyticsService) revenueDrivers(ctx context.Context, windowDays int) (models.RevenueDrivers, error) {
+	as = as.scoped(ctx)
+	openDeals, err := as.DataService.GetOpenDeals(ctx)
+	if err != nil {
+		return models.RevenueDrivers{}, err
+	}
 	pipelineSize := 0.0
 	dealCount := 0
 
@@ -64,13 +129,43 @@ func (as *AnalyticsService) GetRevenueDrivers() models.RevenueDrivers {
 		}
 	}
 
-	closedWonDeals := as.DataService.GetClosedWonDeals()
+	closedWonDeals, err := as.DataService.GetClosedWonDeals(ctx)
+	if err != nil {
+		return models.RevenueDrivers{}, err
+	}
 	totalClosedWon := len(closedWonDeals)
 
 	totalDeals := len(as.DataService.Deals)
-	winRate := 0.0
+	allDealWinRate := 0.0
 	if totalDeals > 0 {
-		winRate = (float64(totalClosedWon) / float64(totalDeals)) * 100
+		allDealWinRate = (float64(totalClosedWon) / float64(totalDeals)) * 100
+	}
+
+	asOf := as.DataService.GetAsOfDate()
+	windowStart := asOf.AddDate(0, 0, -windowDays)
+	won, closed := 0, 0
+	for _, deal := range as.DataService.Deals {
+		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
+			continue
+		}
+		if windowDays > 0 {
+			if deal.ClosedAt == nil || *deal.ClosedAt == "" {
+				continue
+			}
+			closedDate, err := as.DataService.ParseDate(*deal.ClosedAt)
+			if err != nil || !closedDate.After(windowStart) || closedDate.After(asOf) {
+				continue
+			}
+		}
+		closed++
+		if deal.Stage == "Closed Won" {
+			won++
+		}
+	}
+
+	winRate := 0.0
+	if closed > 0 {
+		winRate = (float64(won) / float64(closed)) * 100
 	}
 
 	averageDealSize := 0.0
@@ -100,314 +195,101 @@ func (as *AnalyticsService) GetRevenueDrivers() models.RevenueDrivers {
 	}
 
 	return models.RevenueDrivers{
-		PipelineSize:    pipelineSize,
-		WinRate:         winRate,
-		AverageDealSize: averageDealSize,
-		SalesCycleTime:  avgSalesCycleTime,
-	}
-}
-
-func (as *AnalyticsService) GetRiskFactors() []models.RiskFactor {
-	risks := []models.RiskFactor{}
-
-	staleDeals := as.findStaleDeals()
-	if len(staleDeals) > 0 {
-		risks = append(risks, models.RiskFactor{
-			Type:        "stale_deals",
-			Description: fmt.Sprintf("Found %d deals older than 60 days without recent activity", len(staleDeals)),
-			Severity:    "high",
-			Data: map[string]interface{}{
-				"count": len(staleDeals),
-				"deals": staleDeals[:min(5, len(staleDeals))],
-			},
-		})
-	}
-
-	underperformingReps := as.findUnderperformingReps()
-	if len(underperformingReps) > 0 {
-		risks = append(risks, models.RiskFactor{
-			Type:        "underperforming_reps",
-			Description: fmt.Sprintf("Found %d sales reps with win rate below 20%%", len(underperformingReps)),
-			Severity:    "medium",
-			Data: map[string]interface{}{
-				"count": len(underperformingReps),
-				"reps":  underperformingReps,
-			},
-		})
-	}
-
-	lowActivityAccounts := as.findLowActivityAccounts()
-	if len(lowActivityAccounts) > 0 {
-		risks = append(risks, models.RiskFactor{
-			Type:        "low_activity_accounts",
-			Description: fmt.Sprintf("Found %d accounts with open deals but low activity", len(lowActivityAccounts)),
-			Severity:    "medium",
-			Data: map[string]interface{}{
-				"count":    len(lowActivityAccounts),
-				"accounts": lowActivityAccounts[:min(5, len(lowActivityAccounts))],
-			},
-		})
-	}
-
-	return risks
+		PipelineSize:      pipelineSize,
+		WinRate:           winRate,
+		WinRateWindowDays: windowDays,
+		ClosedDeals:       closed,
+		AllDealWinRate:    allDealWinRate,
+		AverageDealSize:   averageDealSize,
+		SalesCycleTime:    avgSalesCycleTime,
+	}, nil
 }
 
-func (as *AnalyticsService) findStaleDeals() []map[string]interface{} {
-	staleDeals := []map[string]interface{}{}
-	cutoffDate := time.Now().AddDate(0, 0, -60)
-
-	for _, deal := range as.DataService.Deals {
-		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
-			createdDate, err := as.DataService.ParseDate(deal.CreatedAt)
-			if err == nil && createdDate.Before(cutoffDate) {
-				account := as.DataService.GetAccountByID(deal.AccountID)
-				rep := as.DataService.GetRepByID(deal.RepID)
-				age := as.DataService.GetDealAge(deal)
-				activityCount := as.DataService.GetActivityCount(deal.DealID)
-
-				dealInfo := map[string]interface{}{
-					"deal_id":        deal.DealID,
-					"account_name":   account.Name,
-					"rep_name":       rep.Name,
-					"stage":          deal.Stage,
-					"age_days":       age,
-					"activity_count": activityCount,
-					"segment":        account.Segment,
-				}
-
-				if deal.Amount != nil {
-					dealInfo["amount"] = *deal.Amount
-				}
-
-				staleDeals = append(staleDeals, dealInfo)
-			}
-		}
-	}
-
-	return staleDeals
+// GetRiskFactors evaluates every non-internal rule in the rule engine and
+// reports those with at least one match, most severe first. Each risk lists
+// its matches in the rule's default order, up to the rule's limit; the full
+// list is available from GetRiskFactorDetail.
+func (as *AnalyticsService) GetRiskFactors(ctx context.Context) ([]models.RiskFactor, error) {
+	return cached(ctx, as, "risk-factors", as.riskFactors)
 }
 
-func (as *AnalyticsService) findUnderperformingReps() []map[string]interface{} {
-	underperforming := []map[string]interface{}{}
-	repStats := make(map[string]struct {
-		TotalDeals int
-		WonDeals   int
-		RepName    string
-	})
-
-	for _, deal := range as.DataService.Deals {
-		stats := repStats[deal.RepID]
-		if stats.RepName == "" {
-			rep := as.DataService.GetRepByID(deal.RepID)
-			if rep != nil {
-				stats.RepName = rep.Name
-			}
-		}
-		stats.TotalDeals++
-		if deal.Stage == "Closed Won" {
-			stats.WonDeals++
-		}
-		repStats[deal.RepID] = stats
+func (as *AnalyticsService) riskFactors(ctx context.Context) ([]models.RiskFactor, error) {
+	as = as.scoped(ctx)
+	risks := []models.RiskFactor{}
+	exposureBase, err := as.riskExposureBase(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	for repID, stats := range repStats {
-		winRate := 0.0
-		if stats.TotalDeals > 0 {
-			winRate = (float64(stats.WonDeals) / float64(stats.TotalDeals)) * 100
+	for _, rule := range as.RuleEngine.Rules() {
+		if rule.Internal {
+			continue
 		}
 
-		if winRate < 20.0 && stats.TotalDeals >= 5 {
-			underperforming = append(underperforming, map[string]interface{}{
-				"rep_id":      repID,
-				"rep_name":    stats.RepName,
-				"win_rate":    winRate,
-				"total_deals": stats.TotalDeals,
-				"won_deals":   stats.WonDeals,
-			})
+		risk, count, err := as.buildRiskFactor(ctx, rule, exposureBase)
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	return underperforming
-}
-
-func (as *AnalyticsService) findLowActivityAccounts() []map[string]interface{} {
-	lowActivity := []map[string]interface{}{}
-	accountDeals := make(map[string][]models.Deal)
-
-	for _, deal := range as.DataService.Deals {
-		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
-			accountDeals[deal.AccountID] = append(accountDeals[deal.AccountID], deal)
+		if count == 0 {
+			continue
 		}
-	}
 
-	for accountID, deals := range accountDeals {
-		totalActivity := 0
-		for _, deal := range deals {
-			totalActivity += as.DataService.GetActivityCount(deal.DealID)
+		pageSize := rule.Limit
+		if pageSize <= 0 {
+			pageSize = count
 		}
-
-		avgActivity := float64(totalActivity) / float64(len(deals))
-		if avgActivity < 2.0 {
-			account := as.DataService.GetAccountByID(accountID)
-			lowActivity = append(lowActivity, map[string]interface{}{
-				"account_id":          accountID,
-				"account_name":        account.Name,
-				"segment":             account.Segment,
-				"industry":            account.Industry,
-				"open_deals":          len(deals),
-				"total_activities":    totalActivity,
-				"avg_activities":      avgActivity,
-			})
+		if risk.Data, _, err = applyRiskQuery(risk.Data, rule.DefaultQuery(pageSize)); err != nil {
+			return nil, err
 		}
-	}
-
-	return lowActivity
-}
-
-func (as *AnalyticsService) GetRecommendations() []models.Recommendation {
-	recommendations := []models.Recommendation{}
-
-	staleEnterpriseDeals := as.findStaleEnterpriseDeals()
-	if staleEnterpriseDeals > 0 {
-		recommendations = append(recommendations, models.Recommendation{
-			Priority:    "high",
-			Action:      "Focus on Enterprise deals older than 30 days",
-			Impact:      fmt.Sprintf("Potential revenue at risk: $%.0f", staleEnterpriseDeals),
-			Description: "Enterprise deals have the highest value but are moving slowly. Engage with decision-makers to accelerate closure.",
-		})
-	}
-
-	underperformingReps := as.findUnderperformingReps()
-	if len(underperformingReps) > 0 {
-		topRep := underperformingReps[0]
-		recommendations = append(recommendations, models.Recommendation{
-			Priority:    "medium",
-			Action:      fmt.Sprintf("Coach %s on win rate improvement", topRep["rep_name"]),
-			Impact:      fmt.Sprintf("Current win rate: %.1f%%, Target: 25%%", topRep["win_rate"]),
-			Description: "Provide training on objection handling and closing techniques.",
-		})
-	}
-
-	lowActivitySegments := as.findLowActivitySegments()
-	if lowActivitySegments != "" {
-		recommendations = append(recommendations, models.Recommendation{
-			Priority:    "medium",
-			Action:      fmt.Sprintf("Increase activity for %s segment", lowActivitySegments),
-			Impact:      "Expected 15-20% improvement in conversion rate",
-			Description: "Accounts with higher engagement show better conversion rates. Schedule regular check-ins and demos.",
-		})
-	}
 
-	pipelineHealth := as.assessPipelineHealth()
-	if pipelineHealth != "" {
-		recommendations = append(recommendations, models.Recommendation{
-			Priority:    "high",
-			Action:      pipelineHealth,
-			Impact:      "Ensure target achievement for next quarter",
-			Description: "Pipeline should be 3-4x of quarterly target for healthy conversion.",
-		})
+		risks = append(risks, risk)
 	}
 
-	fastTrackDeals := as.findFastTrackOpportunities()
-	if fastTrackDeals > 0 {
-		recommendations = append(recommendations, models.Recommendation{
-			Priority:    "medium",
-			Action:      "Fast-track deals in Negotiation stage",
-			Impact:      fmt.Sprintf("%d deals worth prioritizing", fastTrackDeals),
-			Description: "Deals in negotiation are close to closure. Provide additional resources or executive support.",
-		})
-	}
-
-	return recommendations
-}
-
-func (as *AnalyticsService) findStaleEnterpriseDeals() float64 {
-	cutoffDate := time.Now().AddDate(0, 0, -30)
-	totalValue := 0.0
-
-	for _, deal := range as.DataService.Deals {
-		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
-			account := as.DataService.GetAccountByID(deal.AccountID)
-			if account != nil && account.Segment == "Enterprise" {
-				createdDate, err := as.DataService.ParseDate(deal.CreatedAt)
-				if err == nil && createdDate.Before(cutoffDate) && deal.Amount != nil {
-					totalValue += *deal.Amount
-				}
-			}
+	sort.SliceStable(risks, func(i, j int) bool {
+		if risks[i].Score != risks[j].Score {
+			return risks[i].Score > risks[j].Score
 		}
-	}
-
-	return totalValue
-}
-
-func (as *AnalyticsService) findLowActivitySegments() string {
-	segmentActivity := make(map[string]struct {
-		TotalDeals     int
-		TotalActivity  int
+		return risks[i].ValueAtRisk > risks[j].ValueAtRisk
 	})
 
-	for _, deal := range as.DataService.Deals {
-		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
-			account := as.DataService.GetAccountByID(deal.AccountID)
-			if account != nil {
-				stats := segmentActivity[account.Segment]
-				stats.TotalDeals++
-				stats.TotalActivity += as.DataService.GetActivityCount(deal.DealID)
-				segmentActivity[account.Segment] = stats
-			}
-		}
-	}
-
-	lowestSegment := ""
-	lowestAvg := 999999.0
-
-	for segment, stats := range segmentActivity {
-		if stats.TotalDeals > 0 {
-			avg := float64(stats.TotalActivity) / float64(stats.TotalDeals)
-			if avg < lowestAvg {
-				lowestAvg = avg
-				lowestSegment = segment
-			}
-		}
-	}
-
-	if lowestAvg < 3.0 {
-		return lowestSegment
-	}
-
-	return ""
+	return risks, nil
 }
 
-func (as *AnalyticsService) assessPipelineHealth() string {
-	quarter, year := as.DataService.GetCurrentQuarter()
-	nextQuarter := quarter + 1
-	nextYear := year
-	if nextQuarter > 4 {
-		nextQuarter = 1
-		nextYear++
-	}
-
-	target := as.DataService.GetQuarterTarget(nextQuarter, nextYear)
-	openDeals := as.DataService.GetOpenDeals()
-	pipelineValue := 0.0
+// riskExposureBase is the amount risks are scored against: the open
+// pipeline. Every risk's value is part of it (matching deals, the pipeline
+// of matching accounts) or a fraction of it (revenue reps are expected to
+// lose), so scores spread over 0-100 rather than saturating as they do
+// against a quarter gap far smaller than the pipeline.
+func (as *AnalyticsService) riskExposureBase(ctx context.Context) (float64, error) {
+	drivers, err := as.GetRevenueDrivers(ctx, 0)
+	if err != nil {
+		return 0, err
+	}
+	return drivers.PipelineSize, nil
+}
 
-	for _, deal := range openDeals {
-		if deal.Amount != nil {
-			pipelineValue += *deal.Amount
-		}
+// buildRiskFactor evaluates the rule and scores the result by its value at
+// risk as a percentage of exposureBase. Severity is taken from the rule
+// engine's severity bands. It also returns the number of matches.
+func (as *AnalyticsService) buildRiskFactor(ctx context.Context, rule RiskRule, exposureBase float64) (models.RiskFactor, int, error) {
+	result, data, err := as.EvaluateRule(ctx, rule)
+	if err != nil {
+		return models.RiskFactor{}, 0, err
 	}
 
-	ratio := pipelineValue / target
-	if ratio < 3.0 {
-		return "Increase pipeline coverage - currently below 3x target"
+	score := 0.0
+	if exposureBase > 0 {
+		score = result.TotalAmount / exposureBase * 100
 	}
 
-	return ""
-}
-
-func (as *AnalyticsService) findFastTrackOpportunities() int {
-	negotiationDeals := as.DataService.GetDealsInStage("Negotiation")
-	return len(negotiationDeals)
+	return models.RiskFactor{
+		Type:        rule.Type,
+		Description: result.Describe(),
+		Severity:    as.RuleEngine.Severity(score, rule.Severity),
+		Score:       score,
+		ValueAtRisk: result.TotalAmount,
+		Data:        data,
+	}, result.Count, nil
 }
 
 func min(a, b int) int {
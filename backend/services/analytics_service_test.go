@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+// TestRiskFactorScoresSpread checks that the bundled data's risks are not
+// all scored alike: scores stay below 100 and map to different severities.
+func TestRiskFactorScoresSpread(t *testing.T) {
+	as := newTestAnalyticsService(t)
+	risks, err := as.GetRiskFactors(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(risks) < 2 {
+		t.Fatalf("got %d risks, want at least 2", len(risks))
+	}
+
+	severities := make(map[string]bool)
+	for i, risk := range risks {
+		if risk.Score <= 0 || risk.Score >= 100 {
+			t.Errorf("%s: score = %v, want within (0, 100)", risk.Type, risk.Score)
+		}
+		if i > 0 && risk.Score >= risks[i-1].Score {
+			t.Errorf("%s: score %v does not rank below %s at %v", risk.Type, risk.Score, risks[i-1].Type, risks[i-1].Score)
+		}
+		severities[risk.Severity] = true
+	}
+	if len(severities) < 2 {
+		t.Errorf("every risk has severity %v, want them told apart", severities)
+	}
+}
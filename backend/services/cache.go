@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"revenue-intelligence-api/models"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxCacheEntries bounds the cache; it is emptied when full, so arbitrary
+// query parameters cannot grow it without limit.
+const maxCacheEntries = 1000
+
+// resultCache holds analytics results computed for one Version. Results are
+// shared between callers and must not be modified.
+type resultCache struct {
+	mu      sync.Mutex
+	version string
+	entries map[string]interface{}
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{entries: make(map[string]interface{})}
+}
+
+func (c *resultCache) get(version, key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != version {
+		return nil, false
+	}
+	v, ok := c.entries[key]
+	return v, ok
+}
+
+func (c *resultCache) put(version, key string, v interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != version || len(c.entries) >= maxCacheEntries {
+		c.version = version
+		c.entries = make(map[string]interface{})
+	}
+	c.entries[key] = v
+}
+
+// Version identifies everything analytics results depend on besides the
+// request: the loaded dataset, the risk rules, the recommendation feedback
+// and the as-of date. It changes when data is reloaded, rules are reloaded,
+// feedback is saved or the as-of date moves.
+func (as *AnalyticsService) Version() string {
+	return fmt.Sprintf("%x.%d.%d.%s",
+		as.DataService.LoadedAt.UnixNano(),
+		as.RuleEngine.Version(),
+		as.Feedback.Version(),
+		as.DataService.GetAsOfDate().Format("20060102"))
+}
+
+// ScopeKey identifies the data the context's caller may see, so results
+// computed for one caller are only reused for callers who see the same.
+func ScopeKey(ctx context.Context) string {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok || p.Role == models.RoleCRO {
+		return ""
+	}
+	repIDs := append([]string{}, p.RepIDs...)
+	sort.Strings(repIDs)
+	return p.Role + ":" + strings.Join(repIDs, ",")
+}
+
+// cached returns the result of compute for key, computing it once per
+// Version and caller scope. Errors are not cached.
+func cached[T any](ctx context.Context, as *AnalyticsService, key string, compute func(context.Context) (T, error)) (T, error) {
+	if as.cache == nil {
+		return compute(ctx)
+	}
+	version := as.Version()
+	key = ScopeKey(ctx) + "|" + key
+	if v, ok := as.cache.get(version, key); ok {
+		return v.(T), nil
+	}
+	result, err := compute(ctx)
+	if err != nil {
+		return result, err
+	}
+	as.cache.put(version, key, result)
+	return result, nil
+}
+
+// cacheKey is the query's canonical form, with filters in name order.
+func (q RiskQuery) cacheKey() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "sort=%s&order=%s&page=%d&page_size=%d", q.Sort, q.Order, q.Page, q.PageSize)
+	if q.MinAmount != nil {
+		fmt.Fprintf(&b, "&min_amount=%g", *q.MinAmount)
+	}
+	if q.MaxAmount != nil {
+		fmt.Fprintf(&b, "&max_amount=%g", *q.MaxAmount)
+	}
+	names := make([]string, 0, len(q.Filters))
+	for name := range q.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "&%q=%q", name, q.Filters[name])
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"revenue-intelligence-api/models"
+	"sort"
+)
+
+var CohortMilestoneDays = []int{30, 60, 90, 120}
+
+// GetCohorts groups deals by the month they were created and reports, for
+// each cohort, the deals won and lost within 30/60/90/120 days of creation.
+// WinRate is won / (won + lost) among deals closed by the milestone, and
+// ConversionRate is won / all deals in the cohort. A milestone is matured
+// once every deal in the cohort has been open that long as of GetAsOfDate.
+// Closed deals without a close date count toward the cohort size only.
+func (as *AnalyticsService) GetCohorts(ctx context.Context, segment string) (models.CohortResponse, error) {
+	return cached(ctx, as, "cohorts?segment="+segment, func(ctx context.Context) (models.CohortResponse, error) {
+		return as.cohorts(ctx, segment)
+	})
+}
+
+func (as *AnalyticsService) cohorts(ctx context.Context, segment string) (models.CohortResponse, error) {
+	as = as.scoped(ctx)
+	if err := ctx.Err(); err != nil {
+		return models.CohortResponse{}, err
+	}
+	if segment != "" && !contains(as.DataService.GetSegments(), segment) {
+		return models.CohortResponse{}, fmt.Errorf("unknown segment %q", segment)
+	}
+
+	asOf := as.DataService.GetAsOfDate()
+	cohorts := make(map[string]*models.Cohort)
+
+	for _, deal := range as.DataService.Deals {
+		if segment != "" {
+			account := as.DataService.GetAccountByID(deal.AccountID)
+			if account == nil || account.Segment != segment {
+				continue
+			}
+		}
+
+		created, err := as.DataService.ParseDate(deal.CreatedAt)
+		if err != nil {
+			continue
+		}
+
+		month := created.Format("2006-01")
+		cohort := cohorts[month]
+		if cohort == nil {
+			cohort = &models.Cohort{CohortMonth: month}
+			for _, days := range CohortMilestoneDays {
+				cohort.Milestones = append(cohort.Milestones, models.CohortMilestone{Days: days})
+			}
+			cohorts[month] = cohort
+		}
+
+		cohort.Deals++
+		if deal.Amount != nil {
+			cohort.TotalAmount += *deal.Amount
+		}
+
+		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
+			continue
+		}
+		if deal.ClosedAt == nil || *deal.ClosedAt == "" {
+			continue
+		}
+		closed, err := as.DataService.ParseDate(*deal.ClosedAt)
+		if err != nil || closed.Before(created) {
+			continue
+		}
+
+		age := int(closed.Sub(created).Hours() / 24)
+		for i := range cohort.Milestones {
+			m := &cohort.Milestones[i]
+			if age > m.Days {
+				continue
+			}
+			if deal.Stage == "Closed Won" {
+				m.WonDeals++
+				if deal.Amount != nil {
+					m.WonAmount += *deal.Amount
+				}
+			} else {
+				m.LostDeals++
+			}
+		}
+	}
+
+	months := make([]string, 0, len(cohorts))
+	for month := range cohorts {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	response := models.CohortResponse{
+		Segment:  segment,
+		AsOfDate: asOf.Format("2006-01-02"),
+		Cohorts:  []models.Cohort{},
+	}
+	for _, month := range months {
+		cohort := cohorts[month]
+		cohortStart, _ := as.DataService.ParseDate(month + "-01")
+		lastCreated := cohortStart.AddDate(0, 1, -1)
+
+		for i := range cohort.Milestones {
+			m := &cohort.Milestones[i]
+			m.Matured = !lastCreated.AddDate(0, 0, m.Days).After(asOf)
+			if m.WonDeals+m.LostDeals > 0 {
+				m.WinRate = (float64(m.WonDeals) / float64(m.WonDeals+m.LostDeals)) * 100
+			}
+			if cohort.Deals > 0 {
+				m.ConversionRate = (float64(m.WonDeals) / float64(cohort.Deals)) * 100
+			}
+		}
+		response.Cohorts = append(response.Cohorts, *cohort)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"revenue-intelligence-api/models"
+	"time"
+)
+
+// Snapshot returns the service computing over the current risk rules and
+// feedback, unaffected by later reloads or changes, with the present pinned
+// to now. Results computed from one snapshot are mutually consistent.
+func (as *AnalyticsService) Snapshot(now time.Time) *AnalyticsService {
+	snapshot := *as
+	snapshot.DataService = as.DataService.At(now)
+	snapshot.RuleEngine = as.RuleEngine.Snapshot()
+	snapshot.Feedback = as.Feedback.Snapshot()
+	return &snapshot
+}
+
+// GetDashboard returns the summary, drivers over windowDays, risk factors and
+// recommendations from one snapshot. They share the cached aggregates the
+// individual endpoints use, so each is computed at most once per Version.
+func (as *AnalyticsService) GetDashboard(ctx context.Context, windowDays int) (models.DashboardResponse, error) {
+	as = as.Snapshot(as.DataService.Now())
+	summary, err := as.GetSummary(ctx)
+	if err != nil {
+		return models.DashboardResponse{}, err
+	}
+	drivers, err := as.GetRevenueDrivers(ctx, windowDays)
+	if err != nil {
+		return models.DashboardResponse{}, err
+	}
+	risks, err := as.GetRiskFactors(ctx)
+	if err != nil {
+		return models.DashboardResponse{}, err
+	}
+	recommendations, err := as.GetRecommendations(ctx)
+	if err != nil {
+		return models.DashboardResponse{}, err
+	}
+
+	return models.DashboardResponse{
+		AsOfDate:        as.DataService.GetAsOfDate().Format("2006-01-02"),
+		Summary:         summary,
+		Drivers:         drivers,
+		RiskFactors:     risks,
+		Recommendations: recommendations,
+	}, nil
+}
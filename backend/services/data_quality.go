@@ -0,0 +1,136 @@
+package services
+
+import (
+	"revenue-intelligence-api/models"
+	"strings"
+)
+
+const (
+	QualityError   = "error"
+	QualityWarning = "warning"
+
+	// maxQualityExamples caps the record IDs listed per check.
+	maxQualityExamples = 5
+)
+
+var dealStages = []string{"Prospecting", "Negotiation", "Closed Won", "Closed Lost"}
+
+type qualityCheck struct {
+	name        string
+	entity      string
+	severity    string
+	description string
+}
+
+var qualityChecks = []qualityCheck{
+	{"duplicate_account_id", "account", QualityError, "Account ID used by more than one account"},
+	{"duplicate_rep_id", "rep", QualityError, "Rep ID used by more than one rep"},
+	{"duplicate_deal_id", "deal", QualityError, "Deal ID used by more than one deal"},
+	{"deal_unknown_account", "deal", QualityError, "Deal references an account that does not exist"},
+	{"deal_unknown_rep", "deal", QualityError, "Deal references a rep that does not exist"},
+	{"deal_unknown_stage", "deal", QualityError, "Deal stage is not one of " + strings.Join(dealStages, ", ")},
+	{"deal_invalid_created_at", "deal", QualityError, "Deal created_at is not a YYYY-MM-DD date"},
+	{"deal_invalid_closed_at", "deal", QualityError, "Deal closed_at is not a YYYY-MM-DD date"},
+	{"activity_unknown_deal", "activity", QualityError, "Activity references a deal that does not exist"},
+	{"activity_invalid_timestamp", "activity", QualityError, "Activity timestamp is not a YYYY-MM-DD date"},
+	{"target_invalid_month", "target", QualityError, "Target month is not a YYYY-MM month"},
+	{"deal_missing_amount", "deal", QualityWarning, "Deal has no amount and is left out of value totals"},
+	{"closed_deal_missing_closed_at", "deal", QualityWarning, "Closed deal has no closed_at and is left out of dated metrics"},
+	{"open_deal_has_closed_at", "deal", QualityWarning, "Open deal has a closed_at"},
+	{"deal_closed_before_created", "deal", QualityWarning, "Deal closed_at is before its created_at"},
+}
+
+// CheckQuality runs every data-quality check over the dataset, in a fixed
+// order, including checks without violations.
+func (ds *DataService) CheckQuality() []models.DataQualityCheck {
+	violations := make(map[string][]string)
+	flag := func(check, id string) {
+		violations[check] = append(violations[check], id)
+	}
+
+	accounts := make(map[string]bool)
+	for _, account := range ds.Accounts {
+		if accounts[account.AccountID] {
+			flag("duplicate_account_id", account.AccountID)
+		}
+		accounts[account.AccountID] = true
+	}
+	reps := make(map[string]bool)
+	for _, rep := range ds.Reps {
+		if reps[rep.RepID] {
+			flag("duplicate_rep_id", rep.RepID)
+		}
+		reps[rep.RepID] = true
+	}
+
+	deals := make(map[string]bool)
+	for _, deal := range ds.Deals {
+		if deals[deal.DealID] {
+			flag("duplicate_deal_id", deal.DealID)
+		}
+		deals[deal.DealID] = true
+
+		if !accounts[deal.AccountID] {
+			flag("deal_unknown_account", deal.DealID)
+		}
+		if !reps[deal.RepID] {
+			flag("deal_unknown_rep", deal.DealID)
+		}
+		if !contains(dealStages, deal.Stage) {
+			flag("deal_unknown_stage", deal.DealID)
+		}
+		if deal.Amount == nil {
+			flag("deal_missing_amount", deal.DealID)
+		}
+
+		created, createdErr := ds.ParseDate(deal.CreatedAt)
+		if createdErr != nil {
+			flag("deal_invalid_created_at", deal.DealID)
+		}
+		closed := deal.Stage == "Closed Won" || deal.Stage == "Closed Lost"
+		hasClosedAt := deal.ClosedAt != nil && *deal.ClosedAt != ""
+		switch {
+		case closed && !hasClosedAt:
+			flag("closed_deal_missing_closed_at", deal.DealID)
+		case !closed && hasClosedAt:
+			flag("open_deal_has_closed_at", deal.DealID)
+		}
+		if hasClosedAt {
+			closedAt, err := ds.ParseDate(*deal.ClosedAt)
+			if err != nil {
+				flag("deal_invalid_closed_at", deal.DealID)
+			} else if createdErr == nil && closedAt.Before(created) {
+				flag("deal_closed_before_created", deal.DealID)
+			}
+		}
+	}
+
+	for _, activity := range ds.Activities {
+		if !deals[activity.DealID] {
+			flag("activity_unknown_deal", activity.ActivityID)
+		}
+		if _, err := ds.ParseDate(activity.Timestamp); err != nil {
+			flag("activity_invalid_timestamp", activity.ActivityID)
+		}
+	}
+
+	for _, target := range ds.Targets {
+		if _, err := ds.ParseDate(target.Month + "-01"); err != nil {
+			flag("target_invalid_month", target.Month)
+		}
+	}
+
+	results := make([]models.DataQualityCheck, 0, len(qualityChecks))
+	for _, check := range qualityChecks {
+		ids := violations[check.name]
+		results = append(results, models.DataQualityCheck{
+			Check:       check.name,
+			Entity:      check.entity,
+			Severity:    check.severity,
+			Description: check.description,
+			Violations:  len(ids),
+			Examples:    append([]string{}, ids[:min(maxQualityExamples, len(ids))]...),
+		})
+	}
+	return results
+}
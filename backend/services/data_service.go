@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -9,20 +10,78 @@ import (
 	"time"
 )
 
+// DataService holds the loaded dataset. Query methods that scan it take a
+// context and return its error once it is done, so a request that times out
+// stops at the next lookup.
 type DataService struct {
 	Accounts   []models.Account
 	Reps       []models.Rep
 	Deals      []models.Deal
 	Activities []models.Activity
 	Targets    []models.Target
+
+	Calendar FiscalCalendar
+
+	// Quality holds the data-quality checks run when the data was loaded.
+	Quality      []models.DataQualityCheck
+	LoadedAt     time.Time
+	LoadDuration time.Duration
+
+	// now, when set, pins the time analytics treat as the present, see At.
+	now time.Time
+
+	// company is the full dataset a ForContext result was narrowed from,
+	// and nil for a dataset that is not narrowed.
+	company *DataService
+}
+
+// FiscalCalendar defines the quarters analytics are reported in. A fiscal
+// year that does not start in January is named after the calendar year it
+// ends in, so with StartMonth 2 FY2026 runs from February 2025 to January
+// 2026.
+type FiscalCalendar struct {
+	StartMonth int `json:"start_month"`
+
+	// CurrentQuarter and CurrentYear fix the quarter treated as current.
+	// When CurrentQuarter is 0 it is the fiscal quarter containing today.
+	CurrentQuarter int `json:"current_quarter"`
+	CurrentYear    int `json:"current_year"`
+}
+
+// DefaultFiscalCalendar uses calendar quarters with Q4 2025, the most
+// recent complete quarter in the bundled dataset, as the current quarter.
+var DefaultFiscalCalendar = FiscalCalendar{StartMonth: 1, CurrentQuarter: 4, CurrentYear: 2025}
+
+// QuarterRange returns the first and last day of a fiscal quarter.
+func (c FiscalCalendar) QuarterRange(quarter, year int) (time.Time, time.Time) {
+	startYear := year
+	if c.StartMonth != 1 {
+		startYear--
+	}
+	start := time.Date(startYear, time.Month(c.StartMonth+(quarter-1)*3), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 3, -1)
+}
+
+// QuarterOf returns the fiscal quarter and year containing t.
+func (c FiscalCalendar) QuarterOf(t time.Time) (int, int) {
+	quarter := (int(t.Month())-c.StartMonth+12)%12/3 + 1
+	year := t.Year()
+	if c.StartMonth != 1 && int(t.Month()) >= c.StartMonth {
+		year++
+	}
+	return quarter, year
 }
 
 func NewDataService(dataPath string) (*DataService, error) {
-	ds := &DataService{}
+	ds := &DataService{Calendar: DefaultFiscalCalendar}
 
+	start := time.Now()
 	if err := ds.loadData(dataPath); err != nil {
 		return nil, err
 	}
+	ds.Quality = ds.CheckQuality()
+	ds.LoadedAt = time.Now()
+	ds.LoadDuration = ds.LoadedAt.Sub(start)
 
 	return ds, nil
 }
@@ -55,22 +114,21 @@ func (ds *DataService) loadJSON(filename string, v interface{}) error {
 	return json.Unmarshal(data, v)
 }
 
+// GetCurrentQuarter returns the fiscal quarter and year treated as current,
+// see FiscalCalendar.
 func (ds *DataService) GetCurrentQuarter() (int, int) {
-	// Analyzing 2025 data - showing Q4 2025 as the "current" quarter
-	// since that's the most recent complete quarter in the dataset
-	// In production, this would use time.Now() for real-time analysis
-	year := 2025
-	quarter := 4
-	return quarter, year
+	if ds.Calendar.CurrentQuarter == 0 {
+		return ds.Calendar.QuarterOf(ds.Now())
+	}
+	return ds.Calendar.CurrentQuarter, ds.Calendar.CurrentYear
 }
 
 func (ds *DataService) GetQuarterMonths(quarter, year int) []string {
 	months := []string{}
-	startMonth := (quarter-1)*3 + 1
+	start, _ := ds.GetQuarterDateRange(quarter, year)
 
 	for i := 0; i < 3; i++ {
-		month := startMonth + i
-		months = append(months, time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC).Format("2006-01"))
+		months = append(months, start.AddDate(0, i, 0).Format("2006-01"))
 	}
 
 	return months
@@ -94,60 +152,111 @@ func (ds *DataService) GetRepByID(repID string) *models.Rep {
 	return nil
 }
 
-func (ds *DataService) GetDealsByAccountID(accountID string) []models.Deal {
+func (ds *DataService) GetDealsByAccountID(ctx context.Context, accountID string) ([]models.Deal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	var deals []models.Deal
 	for _, deal := range ds.Deals {
 		if deal.AccountID == accountID {
 			deals = append(deals, deal)
 		}
 	}
-	return deals
+	return deals, nil
 }
 
-func (ds *DataService) GetActivitiesByDealID(dealID string) []models.Activity {
+func (ds *DataService) GetActivitiesByDealID(ctx context.Context, dealID string) ([]models.Activity, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	var activities []models.Activity
 	for _, activity := range ds.Activities {
 		if activity.DealID == dealID {
 			activities = append(activities, activity)
 		}
 	}
-	return activities
+	return activities, nil
 }
 
-func (ds *DataService) GetClosedWonDeals() []models.Deal {
+// GetLastActivity returns the most recent activity with a valid timestamp
+// for the deal, or nil if there is none.
+func (ds *DataService) GetLastActivity(ctx context.Context, dealID string) (*models.Activity, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var last *models.Activity
+	var lastTime time.Time
+	for i, activity := range ds.Activities {
+		if activity.DealID != dealID {
+			continue
+		}
+		ts, err := ds.ParseDate(activity.Timestamp)
+		if err != nil {
+			continue
+		}
+		if last == nil || ts.After(lastTime) {
+			last = &ds.Activities[i]
+			lastTime = ts
+		}
+	}
+	return last, nil
+}
+
+func (ds *DataService) GetClosedWonDeals(ctx context.Context) ([]models.Deal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	var deals []models.Deal
 	for _, deal := range ds.Deals {
 		if deal.Stage == "Closed Won" && deal.Amount != nil {
 			deals = append(deals, deal)
 		}
 	}
-	return deals
+	return deals, nil
 }
 
-func (ds *DataService) GetOpenDeals() []models.Deal {
+func (ds *DataService) GetOpenDeals(ctx context.Context) ([]models.Deal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	var deals []models.Deal
 	for _, deal := range ds.Deals {
 		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
 			deals = append(deals, deal)
 		}
 	}
-	return deals
+	return deals, nil
+}
+
+// Company returns the full dataset ds was narrowed from by ForContext, or ds
+// itself. Baselines such as the team win rate are computed over it, so a
+// scoped caller is compared with the whole team rather than with themselves.
+func (ds *DataService) Company() *DataService {
+	if ds.company != nil {
+		return ds.company
+	}
+	return ds
 }
 
-func (ds *DataService) GetDealsByRepID(repID string) []models.Deal {
+func (ds *DataService) GetDealsByRepID(ctx context.Context, repID string) ([]models.Deal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	var deals []models.Deal
 	for _, deal := range ds.Deals {
 		if deal.RepID == repID {
 			deals = append(deals, deal)
 		}
 	}
-	return deals
+	return deals, nil
 }
 
 func (ds *DataService) ParseDate(dateStr string) (time.Time, error) {
 	return time.Parse("2006-01-02", dateStr)
 }
 
+// GetDealAge returns the days from creation to close, or to GetAsOfDate for
+// deals without a usable close date.
 func (ds *DataService) GetDealAge(deal models.Deal) int {
 	created, err := ds.ParseDate(deal.CreatedAt)
 	if err != nil {
@@ -158,10 +267,10 @@ func (ds *DataService) GetDealAge(deal models.Deal) int {
 	if deal.ClosedAt != nil && *deal.ClosedAt != "" {
 		endDate, err = ds.ParseDate(*deal.ClosedAt)
 		if err != nil {
-			endDate = time.Now()
+			endDate = ds.GetAsOfDate()
 		}
 	} else {
-		endDate = time.Now()
+		endDate = ds.GetAsOfDate()
 	}
 
 	return int(endDate.Sub(created).Hours() / 24)
@@ -185,7 +294,10 @@ func (ds *DataService) GetQuarterTarget(quarter, year int) float64 {
 	return total
 }
 
-func (ds *DataService) GetQuarterRevenue(quarter, year int) float64 {
+func (ds *DataService) GetQuarterRevenue(ctx context.Context, quarter, year int) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
 	months := ds.GetQuarterMonths(quarter, year)
 	monthMap := make(map[string]bool)
 	for _, m := range months {
@@ -205,17 +317,20 @@ func (ds *DataService) GetQuarterRevenue(quarter, year int) float64 {
 		}
 	}
 
-	return total
+	return total, nil
 }
 
-func (ds *DataService) GetDealsInStage(stage string) []models.Deal {
+func (ds *DataService) GetDealsInStage(ctx context.Context, stage string) ([]models.Deal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	var deals []models.Deal
 	for _, deal := range ds.Deals {
 		if deal.Stage == stage {
 			deals = append(deals, deal)
 		}
 	}
-	return deals
+	return deals, nil
 }
 
 func (ds *DataService) SortDealsByAge(deals []models.Deal) []models.Deal {
@@ -229,12 +344,82 @@ func (ds *DataService) SortDealsByAge(deals []models.Deal) []models.Deal {
 	return sorted
 }
 
-func (ds *DataService) GetActivityCount(dealID string) int {
+func (ds *DataService) GetActivityCount(ctx context.Context, dealID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
 	count := 0
 	for _, activity := range ds.Activities {
 		if activity.DealID == dealID {
 			count++
 		}
 	}
-	return count
+	return count, nil
+}
+
+func (ds *DataService) GetQuarterDateRange(quarter, year int) (time.Time, time.Time) {
+	return ds.Calendar.QuarterRange(quarter, year)
+}
+
+// Now returns the time analytics treat as the present: the time pinned by At,
+// or the current time.
+func (ds *DataService) Now() time.Time {
+	if !ds.now.IsZero() {
+		return ds.now
+	}
+	return time.Now().UTC()
+}
+
+// At returns the dataset with the present pinned to now, so every result
+// computed from it uses the same current quarter and as-of date.
+func (ds *DataService) At(now time.Time) *DataService {
+	pinned := *ds
+	pinned.now = now.UTC()
+	return &pinned
+}
+
+// GetAsOfDate returns the date analytics are evaluated at: the last day of
+// the current quarter, or today if that is earlier.
+func (ds *DataService) GetAsOfDate() time.Time {
+	quarter, year := ds.GetCurrentQuarter()
+	_, end := ds.GetQuarterDateRange(quarter, year)
+	now := ds.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if today.Before(end) {
+		return today
+	}
+	return end
+}
+
+// CloseWindow returns the days open deals can still close in the current
+// quarter: from the as-of date, or the quarter start if that is later, to
+// the quarter end. ok is false once the quarter has ended, as when an
+// earlier quarter is configured as current, since then no open deal can
+// close in it.
+func (ds *DataService) CloseWindow() (from, end time.Time, ok bool) {
+	quarter, year := ds.GetCurrentQuarter()
+	start, end := ds.GetQuarterDateRange(quarter, year)
+	now := ds.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if today.After(end) {
+		return time.Time{}, time.Time{}, false
+	}
+	from = ds.GetAsOfDate()
+	if from.Before(start) {
+		from = start
+	}
+	return from, end, true
+}
+
+func (ds *DataService) GetSegments() []string {
+	seen := make(map[string]bool)
+	segments := []string{}
+	for _, acc := range ds.Accounts {
+		if acc.Segment != "" && !seen[acc.Segment] {
+			seen[acc.Segment] = true
+			segments = append(segments, acc.Segment)
+		}
+	}
+	sort.Strings(segments)
+	return segments
 }
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"revenue-intelligence-api/models"
+	"sort"
+)
+
+const (
+	DefaultNextActionLimit = 10
+	MaxNextActionLimit     = 100
+
+	// The touch cadence is the segment's median gap between activities,
+	// clamped to one to three weeks: gaps in sparsely logged activity
+	// overstate how long a live deal can be left alone.
+	minCadenceDays = 7
+	maxCadenceDays = 21
+)
+
+var (
+	ErrDealNotFound = errors.New("deal not found")
+	ErrDealClosed   = errors.New("deal is closed")
+	ErrRepNotFound  = errors.New("rep not found")
+)
+
+var activityActions = map[string]string{
+	"call":  "call the buyer",
+	"demo":  "schedule a demo",
+	"email": "send a follow-up email",
+}
+
+var nextActionPriorities = map[string]int{"high": 0, "medium": 1, "low": 2}
+
+// GetDealNextAction suggests the next activity for an open deal.
+func (as *AnalyticsService) GetDealNextAction(ctx context.Context, dealID string) (models.DealNextAction, error) {
+	return cached(ctx, as, "deals/"+dealID+"/next-action", func(ctx context.Context) (models.DealNextAction, error) {
+		return as.dealNextAction(ctx, dealID)
+	})
+}
+
+func (as *AnalyticsService) dealNextAction(ctx context.Context, dealID string) (models.DealNextAction, error) {
+	as = as.scoped(ctx)
+	items, err := as.dealRiskItems(ctx)
+	if err != nil {
+		return models.DealNextAction{}, err
+	}
+	for _, item := range items {
+		if item.DealID != dealID {
+			continue
+		}
+		if !item.IsOpen {
+			return models.DealNextAction{}, ErrDealClosed
+		}
+		norms, err := as.activityNorms(ctx)
+		if err != nil {
+			return models.DealNextAction{}, err
+		}
+		return as.nextAction(ctx, item, norms)
+	}
+	return models.DealNextAction{}, ErrDealNotFound
+}
+
+// GetRepNextActions suggests the next activity for each of a rep's open
+// deals and returns the most urgent limit of them: by priority, then due
+// date, then amount.
+func (as *AnalyticsService) GetRepNextActions(ctx context.Context, repID string, limit int) (models.RepNextActions, error) {
+	return cached(ctx, as, fmt.Sprintf("reps/%s/next-actions?limit=%d", repID, limit), func(ctx context.Context) (models.RepNextActions, error) {
+		return as.repNextActions(ctx, repID, limit)
+	})
+}
+
+func (as *AnalyticsService) repNextActions(ctx context.Context, repID string, limit int) (models.RepNextActions, error) {
+	as = as.scoped(ctx)
+	rep := as.DataService.GetRepByID(repID)
+	if rep == nil {
+		return models.RepNextActions{}, ErrRepNotFound
+	}
+	if limit <= 0 {
+		limit = DefaultNextActionLimit
+	}
+	limit = min(limit, MaxNextActionLimit)
+
+	norms, err := as.activityNorms(ctx)
+	if err != nil {
+		return models.RepNextActions{}, err
+	}
+	items, err := as.dealRiskItems(ctx)
+	if err != nil {
+		return models.RepNextActions{}, err
+	}
+	actions := []models.DealNextAction{}
+	for _, item := range items {
+		if item.RepID != repID || !item.IsOpen {
+			continue
+		}
+		action, err := as.nextAction(ctx, item, norms)
+		if err != nil {
+			return models.RepNextActions{}, err
+		}
+		actions = append(actions, action)
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		a, b := actions[i], actions[j]
+		if nextActionPriorities[a.Priority] != nextActionPriorities[b.Priority] {
+			return nextActionPriorities[a.Priority] < nextActionPriorities[b.Priority]
+		}
+		if a.DueInDays != b.DueInDays {
+			return a.DueInDays < b.DueInDays
+		}
+		amountA, amountB := 0.0, 0.0
+		if a.Deal.Amount != nil {
+			amountA = *a.Deal.Amount
+		}
+		if b.Deal.Amount != nil {
+			amountB = *b.Deal.Amount
+		}
+		if amountA != amountB {
+			return amountA > amountB
+		}
+		return a.Deal.DealID < b.Deal.DealID
+	})
+
+	return models.RepNextActions{
+		RepID:     rep.RepID,
+		RepName:   rep.Name,
+		OpenDeals: len(actions),
+		Actions:   actions[:min(limit, len(actions))],
+	}, nil
+}
+
+// activityNorms returns the activity norms of closed deals per segment.
+// Segments with fewer than Thresholds.MinSegmentSample closed deals use the norms of
+// all closed deals, also available under AllSegments.
+func (as *AnalyticsService) activityNorms(ctx context.Context) (map[string]models.ActivityNorms, error) {
+	effectiveness, err := as.GetActivityEffectiveness(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	norms := make(map[string]models.ActivityNorms)
+	var overall models.ActivityNorms
+	for _, e := range effectiveness {
+		n := models.ActivityNorms{
+			Segment:                     e.Segment,
+			ClosedDeals:                 e.ClosedDeals,
+			MedianDaysBetweenActivities: e.MedianDaysBetweenActivities,
+			MedianActivitiesBeforeClose: e.MedianActivitiesBeforeClose,
+			WinRateWithDemo:             e.WinRateWithDemo,
+			WinRateWithoutDemo:          e.WinRateWithoutDemo,
+			AvgPerWonDeal:               make(map[string]float64),
+		}
+		for _, t := range e.ActivityTypes {
+			n.AvgPerWonDeal[t.Type] = t.AvgPerWonDeal
+		}
+		if e.Segment == AllSegments {
+			overall = n
+		}
+		norms[e.Segment] = n
+	}
+
+	for segment, n := range norms {
+		if n.ClosedDeals < as.Thresholds.MinSegmentSample {
+			norms[segment] = overall
+		}
+	}
+	return norms, nil
+}
+
+// nextAction applies the first matching rule:
+//   - high: no activity for over twice the touch cadence
+//   - high in Negotiation, medium otherwise: no demo yet, where closed deals
+//     with a demo win more often
+//   - medium: in Negotiation with fewer activities than the median won deal
+//   - low: on cadence; the next touch is due one cadence after the last
+//
+// The suggested activity is a demo for the demo rule, otherwise the type the
+// deal is furthest below the average won deal on.
+func (as *AnalyticsService) nextAction(ctx context.Context, item models.DealRiskItem, norms map[string]models.ActivityNorms) (models.DealNextAction, error) {
+	n, ok := norms[item.Segment]
+	if !ok {
+		n = norms[AllSegments]
+	}
+
+	activities, err := as.DataService.GetActivitiesByDealID(ctx, item.DealID)
+	if err != nil {
+		return models.DealNextAction{}, err
+	}
+	counts := make(map[string]int)
+	for _, activity := range activities {
+		counts[activity.Type]++
+	}
+
+	action := models.DealNextAction{
+		Deal:           item,
+		ActivityCounts: counts,
+		Activity:       activityShortfall(counts, n),
+		Norms:          n,
+	}
+
+	deals := "deals"
+	if n.Segment != AllSegments {
+		deals = n.Segment + " deals"
+	}
+	cadence := min(max(int(math.Ceil(n.MedianDaysBetweenActivities)), minCadenceDays), maxCadenceDays)
+	daysSince := 0
+	if item.DaysSinceActivity != nil {
+		daysSince = *item.DaysSinceActivity
+	}
+
+	switch {
+	case daysSince > 2*cadence:
+		action.Priority = "high"
+		action.Reason = fmt.Sprintf("No activity for %d days against a %d-day cadence for %s - %s",
+			daysSince, cadence, deals, activityActions[action.Activity])
+
+	case counts["demo"] == 0 && n.WinRateWithDemo > n.WinRateWithoutDemo:
+		action.Activity = "demo"
+		action.Priority = "medium"
+		if item.Stage == "Negotiation" {
+			action.Priority = "high"
+		}
+		action.Reason = fmt.Sprintf("No demo yet %s - %s; %s with a demo won %.0f%% against %.0f%% without",
+			dealTimeInStage(item), activityActions["demo"], "closed "+deals, n.WinRateWithDemo, n.WinRateWithoutDemo)
+
+	case item.Stage == "Negotiation" && float64(item.ActivityCount) < n.MedianActivitiesBeforeClose:
+		action.Priority = "medium"
+		action.Reason = fmt.Sprintf("%d activities against a median of %.0f before close on won %s - %s",
+			item.ActivityCount, n.MedianActivitiesBeforeClose, deals, activityActions[action.Activity])
+
+	default:
+		action.Priority = "low"
+		action.DueInDays = max(cadence-daysSince, 0)
+		action.Reason = fmt.Sprintf("On cadence; next touch due in %d days - %s", action.DueInDays, activityActions[action.Activity])
+	}
+
+	return action, nil
+}
+
+// activityShortfall returns the activity type the deal is furthest below the
+// average won deal on, in name order on ties, or "email" if it is not below
+// on any.
+func activityShortfall(counts map[string]int, n models.ActivityNorms) string {
+	types := make([]string, 0, len(n.AvgPerWonDeal))
+	for activityType := range n.AvgPerWonDeal {
+		types = append(types, activityType)
+	}
+	sort.Strings(types)
+
+	best, bestShortfall := "email", 0.0
+	for _, activityType := range types {
+		if _, ok := activityActions[activityType]; !ok {
+			continue
+		}
+		shortfall := n.AvgPerWonDeal[activityType] - float64(counts[activityType])
+		if shortfall > bestShortfall {
+			best, bestShortfall = activityType, shortfall
+		}
+	}
+	return best
+}
+
+// dealTimeInStage describes how long the deal has been in its stage, or open
+// when the stage change date is unknown.
+func dealTimeInStage(item models.DealRiskItem) string {
+	if item.DaysInStage != nil {
+		return fmt.Sprintf("after %d days in %s", *item.DaysInStage, item.Stage)
+	}
+	if item.AgeDays != nil {
+		return fmt.Sprintf("after %d days open, in %s", *item.AgeDays, item.Stage)
+	}
+	return "in " + item.Stage
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"revenue-intelligence-api/models"
+	"time"
+)
+
+// Readiness checks that the dataset is loaded and passed its error-severity
+// quality checks, that risk rules are loaded and that the feedback store can
+// be written. The data is only loaded at startup, so its age is reported as
+// DataStale rather than failing readiness, which a restart would not fix
+// until the data on disk changes.
+func (as *AnalyticsService) Readiness(now time.Time) models.Readiness {
+	ds := as.DataService
+	rules := as.RuleEngine.LoadStats()
+	readiness := models.Readiness{
+		Ready:              true,
+		DataLoadedAt:       ds.LoadedAt.UTC().Format(time.RFC3339),
+		DataAgeSeconds:     now.Sub(ds.LoadedAt).Seconds(),
+		DataStale:          as.MaxDataAge > 0 && now.Sub(ds.LoadedAt) > as.MaxDataAge,
+		RulesLoadedAt:      rules.LoadedAt.UTC().Format(time.RFC3339),
+		RulesAgeSeconds:    now.Sub(rules.LoadedAt).Seconds(),
+		RuleReloadFailures: rules.Failures,
+	}
+	check := func(name string, err error) {
+		c := models.ReadinessCheck{Name: name, OK: err == nil}
+		if err != nil {
+			c.Message = err.Error()
+			readiness.Ready = false
+		}
+		readiness.Checks = append(readiness.Checks, c)
+	}
+
+	var err error
+	if len(ds.Deals) == 0 {
+		err = errors.New("no deals loaded")
+	}
+	check("data_loaded", err)
+
+	err = nil
+	failed, violations := 0, 0
+	for _, c := range ds.Quality {
+		if c.Severity == QualityError && c.Violations > 0 {
+			failed++
+			violations += c.Violations
+		}
+	}
+	if failed > 0 {
+		err = fmt.Errorf("%d records fail %d error-severity data-quality checks", violations, failed)
+	}
+	check("data_valid", err)
+
+	err = nil
+	if len(as.RuleEngine.Rules()) == 0 {
+		err = errors.New("no risk rules loaded")
+	}
+	check("rules_loaded", err)
+
+	check("feedback_store", as.Feedback.Ping())
+	return readiness
+}
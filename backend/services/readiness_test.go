@@ -0,0 +1,23 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadinessReportsStaleDataWithoutFailing(t *testing.T) {
+	as := newTestAnalyticsService(t)
+	as.MaxDataAge = time.Hour
+
+	fresh := as.Readiness(as.DataService.LoadedAt.Add(time.Minute))
+	if !fresh.Ready || fresh.DataStale {
+		t.Fatalf("fresh data: ready = %v, stale = %v, want ready and not stale", fresh.Ready, fresh.DataStale)
+	}
+	stale := as.Readiness(as.DataService.LoadedAt.Add(48 * time.Hour))
+	if !stale.Ready {
+		t.Errorf("stale data failed readiness: %+v", stale.Checks)
+	}
+	if !stale.DataStale {
+		t.Error("data older than MaxDataAge not reported stale")
+	}
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"revenue-intelligence-api/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRecommendationNotFound = errors.New("recommendation not found")
+
+	// ErrRecommendationNotEvaluable is returned for outcomes of
+	// recommendations that were never accepted or done, so have no baseline.
+	ErrRecommendationNotEvaluable = errors.New("recommendation has not been accepted")
+)
+
+var recommendationStatuses = []string{
+	models.RecommendationOpen,
+	models.RecommendationAccepted,
+	models.RecommendationDismissed,
+	models.RecommendationDone,
+}
+
+// RecommendationStore persists recommendation feedback to a JSON file. It is
+// safe for concurrent use; every change rewrites the file.
+type RecommendationStore struct {
+	path     string
+	mu       sync.RWMutex
+	feedback map[string]models.RecommendationFeedback
+
+	// version counts the changes saved since loading.
+	version int
+
+	// writeErr is why the file could not be written, from the probe at
+	// startup or the last change, and nil once a write succeeds.
+	writeErr error
+}
+
+// NewRecommendationStore loads feedback from path. A missing file is an
+// empty store; it is created on the first change. A directory that cannot
+// be written does not fail loading, but is reported by Ping.
+func NewRecommendationStore(path string) (*RecommendationStore, error) {
+	s := &RecommendationStore{path: path, feedback: make(map[string]models.RecommendationFeedback)}
+	s.writeErr = probeWritable(path)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []models.RecommendationFeedback
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, fb := range records {
+		s.feedback[fb.RecommendationID] = fb
+	}
+	return s, nil
+}
+
+func (s *RecommendationStore) Get(id string) (models.RecommendationFeedback, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fb, ok := s.feedback[id]
+	return fb, ok
+}
+
+// All returns every feedback record ordered by recommendation ID.
+func (s *RecommendationStore) All() []models.RecommendationFeedback {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]models.RecommendationFeedback, 0, len(s.feedback))
+	for _, fb := range s.feedback {
+		records = append(records, fb)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].RecommendationID < records[j].RecommendationID
+	})
+	return records
+}
+
+// Update applies fn to the record for id (a zero record if there is none)
+// and saves it. The file is written to a temporary file and renamed so a
+// failed write leaves the previous state intact.
+func (s *RecommendationStore) Update(id string, fn func(*models.RecommendationFeedback)) (models.RecommendationFeedback, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fb := s.feedback[id]
+	fn(&fb)
+
+	records := make([]models.RecommendationFeedback, 0, len(s.feedback)+1)
+	for key, existing := range s.feedback {
+		if key != id {
+			records = append(records, existing)
+		}
+	}
+	records = append(records, fb)
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].RecommendationID < records[j].RecommendationID
+	})
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fb, err
+	}
+	if err := writeFile(s.path, data); err != nil {
+		s.writeErr = err
+		return fb, err
+	}
+	s.writeErr = nil
+
+	s.feedback[id] = fb
+	s.version++
+	return fb, nil
+}
+
+// Snapshot returns a copy of the store's current feedback for reading;
+// later changes to the store do not affect it.
+func (s *RecommendationStore) Snapshot() *RecommendationStore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot := &RecommendationStore{path: s.path, feedback: make(map[string]models.RecommendationFeedback, len(s.feedback)), version: s.version}
+	for id, fb := range s.feedback {
+		snapshot.feedback[id] = fb
+	}
+	return snapshot
+}
+
+// Version changes whenever feedback is saved.
+func (s *RecommendationStore) Version() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+// Ping reports why the store's file cannot be written, or nil. A healthy
+// store answers from the startup probe and the last change without touching
+// the disk; a failing one probes again, so it recovers once the directory
+// is writable.
+func (s *RecommendationStore) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writeErr != nil {
+		s.writeErr = probeWritable(s.path)
+	}
+	return s.writeErr
+}
+
+// writeFile writes data to a temporary file beside path and renames it over
+// path, so a failed write leaves the previous file intact.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// probeWritable checks that the directory of path accepts the temporary
+// file writeFile starts with.
+func probeWritable(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// GetRecommendationFeedback returns the feedback recorded on every
+// recommendation. Feedback is company-wide, so only a CRO may read or change
+// it.
+func (as *AnalyticsService) GetRecommendationFeedback(ctx context.Context) ([]models.RecommendationFeedback, error) {
+	if err := RequireRole(ctx, models.RoleCRO); err != nil {
+		return nil, err
+	}
+	return as.Feedback.All(), nil
+}
+
+// SetRecommendationStatus records a status change for a recommendation that
+// is currently generated or already has feedback. The first time it is
+// accepted or done, the targeted metric is captured as the baseline for
+// GetRecommendationOutcome.
+func (as *AnalyticsService) SetRecommendationStatus(ctx context.Context, id string, req models.RecommendationFeedbackRequest) (models.RecommendationFeedback, error) {
+	if err := RequireRole(ctx, models.RoleCRO); err != nil {
+		return models.RecommendationFeedback{}, err
+	}
+	if !contains(recommendationStatuses, req.Status) {
+		return models.RecommendationFeedback{}, fmt.Errorf("unknown status %q", req.Status)
+	}
+
+	ranked, err := as.rankedRecommendations(ctx)
+	if err != nil {
+		return models.RecommendationFeedback{}, err
+	}
+	existing, ok := as.Feedback.Get(id)
+	var rec *models.Recommendation
+	for _, candidate := range ranked {
+		if candidate.ID == id {
+			rec = &candidate
+			break
+		}
+	}
+	if rec == nil && !ok {
+		return models.RecommendationFeedback{}, ErrRecommendationNotFound
+	}
+
+	// The baseline is computed before taking the store's lock.
+	var metric string
+	var baseline *float64
+	if existing.Baseline == nil && (req.Status == models.RecommendationAccepted || req.Status == models.RecommendationDone) {
+		target := existing
+		if rec != nil {
+			target.Type = rec.Type
+			target.Evidence = rec.Evidence
+		}
+		name, value, _, err := as.recommendationMetric(ctx, target)
+		if err != nil {
+			return models.RecommendationFeedback{}, err
+		}
+		metric, baseline = name, &value
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	return as.Feedback.Update(id, func(fb *models.RecommendationFeedback) {
+		fb.RecommendationID = id
+		if rec != nil {
+			fb.Type = rec.Type
+			fb.Action = rec.Action
+			fb.Evidence = rec.Evidence
+		}
+		fb.Status = req.Status
+		fb.Note = req.Note
+		fb.UpdatedAt = now
+		if fb.Baseline == nil && baseline != nil {
+			fb.Metric = metric
+			fb.Baseline = baseline
+			fb.BaselineAt = &now
+		}
+		fb.History = append(fb.History, models.RecommendationStatusChange{Status: req.Status, Note: req.Note, At: now})
+	})
+}
+
+// GetRecommendationOutcome compares the targeted metric now with its
+// baseline. The metric is recomputed from the loaded data, so it only moves
+// once the dataset is refreshed.
+func (as *AnalyticsService) GetRecommendationOutcome(ctx context.Context, id string) (models.RecommendationOutcome, error) {
+	if err := RequireRole(ctx, models.RoleCRO); err != nil {
+		return models.RecommendationOutcome{}, err
+	}
+	fb, ok := as.Feedback.Get(id)
+	if !ok {
+		ranked, err := as.rankedRecommendations(ctx)
+		if err != nil {
+			return models.RecommendationOutcome{}, err
+		}
+		for _, rec := range ranked {
+			if rec.ID == id {
+				return models.RecommendationOutcome{}, ErrRecommendationNotEvaluable
+			}
+		}
+		return models.RecommendationOutcome{}, ErrRecommendationNotFound
+	}
+	if fb.Baseline == nil || fb.BaselineAt == nil {
+		return models.RecommendationOutcome{}, ErrRecommendationNotEvaluable
+	}
+
+	metric, current, higherIsBetter, err := as.recommendationMetric(ctx, fb)
+	if err != nil {
+		return models.RecommendationOutcome{}, err
+	}
+
+	change := current - *fb.Baseline
+	return models.RecommendationOutcome{
+		RecommendationID: id,
+		Type:             fb.Type,
+		Status:           fb.Status,
+		Metric:           metric,
+		HigherIsBetter:   higherIsBetter,
+		Baseline:         *fb.Baseline,
+		BaselineAt:       *fb.BaselineAt,
+		Current:          current,
+		Change:           change,
+		Improved:         (higherIsBetter && change > 0) || (!higherIsBetter && change < 0),
+	}, nil
+}
+
+// recommendationMetric returns the metric a recommendation type aims to move,
+// its current value and whether higher is better:
+//   - stale_enterprise_deals: number of stale Enterprise deals
+//   - coach_rep: the rep's win rate over closed deals
+//   - increase_activity: activities per open deal in the segment
+//   - pipeline_coverage: open pipeline
+//   - fast_track_negotiation: won amount of the evidence deals
+func (as *AnalyticsService) recommendationMetric(ctx context.Context, fb models.RecommendationFeedback) (string, float64, bool, error) {
+	switch fb.Type {
+	case "stale_enterprise_deals":
+		rule, ok := as.RuleEngine.Rule("stale_enterprise_deals")
+		if !ok {
+			return "", 0, false, fmt.Errorf("rule %q is not loaded", "stale_enterprise_deals")
+		}
+		result, _, err := as.EvaluateRule(ctx, rule)
+		if err != nil {
+			return "", 0, false, err
+		}
+		return "stale_enterprise_deals", float64(result.Count), false, nil
+
+	case "coach_rep":
+		if len(fb.Evidence.RepIDs) == 0 {
+			break
+		}
+		reps, err := as.repRiskItems(ctx)
+		if err != nil {
+			return "", 0, false, err
+		}
+		for _, rep := range reps {
+			if rep.RepID == fb.Evidence.RepIDs[0] {
+				return "win_rate", rep.WinRate, true, nil
+			}
+		}
+		return "", 0, false, fmt.Errorf("rep %q not found", fb.Evidence.RepIDs[0])
+
+	case "increase_activity":
+		openDeals, err := as.DataService.GetOpenDeals(ctx)
+		if err != nil {
+			return "", 0, false, err
+		}
+		deals, activities := 0, 0
+		for _, deal := range openDeals {
+			account := as.DataService.GetAccountByID(deal.AccountID)
+			if account == nil || account.Segment != fb.Evidence.Segment {
+				continue
+			}
+			count, err := as.DataService.GetActivityCount(ctx, deal.DealID)
+			if err != nil {
+				return "", 0, false, err
+			}
+			deals++
+			activities += count
+		}
+		avg := 0.0
+		if deals > 0 {
+			avg = float64(activities) / float64(deals)
+		}
+		return "activities_per_open_deal", avg, true, nil
+
+	case "pipeline_coverage":
+		openDeals, err := as.DataService.GetOpenDeals(ctx)
+		if err != nil {
+			return "", 0, false, err
+		}
+		pipeline := 0.0
+		for _, deal := range openDeals {
+			if deal.Amount != nil {
+				pipeline += *deal.Amount
+			}
+		}
+		return "open_pipeline", pipeline, true, nil
+
+	case "fast_track_negotiation":
+		won := 0.0
+		for _, id := range fb.Evidence.DealIDs {
+			for _, deal := range as.DataService.Deals {
+				if deal.DealID == id && deal.Stage == "Closed Won" && deal.Amount != nil {
+					won += *deal.Amount
+				}
+			}
+		}
+		return "won_amount", won, true, nil
+	}
+
+	return "", 0, false, fmt.Errorf("no metric for recommendation type %q", fb.Type)
+}
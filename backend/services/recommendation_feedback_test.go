@@ -0,0 +1,44 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"revenue-intelligence-api/models"
+	"testing"
+)
+
+func TestRecommendationStorePing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "feedback")
+	store, err := NewRecommendationStore(filepath.Join(dir, "feedback.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Ping() == nil {
+		t.Fatal("Ping succeeded for a missing directory")
+	}
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Ping(); err != nil {
+		t.Fatalf("Ping after creating the directory: %v", err)
+	}
+	setDone := func(fb *models.RecommendationFeedback) { fb.Status = models.RecommendationDone }
+	if _, err := store.Update("a", setDone); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Update("b", setDone); err == nil {
+		t.Fatal("Update succeeded without a directory")
+	}
+	if store.Ping() == nil {
+		t.Error("Ping succeeded after a failed write")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(dir))
+	if len(entries) != 0 {
+		t.Errorf("files left beside the store: %v", entries)
+	}
+}
@@ -0,0 +1,472 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"revenue-intelligence-api/models"
+	"sort"
+)
+
+// maxEvidenceDeals caps the deal IDs listed as evidence, largest first.
+const maxEvidenceDeals = 10
+
+// winCount is a count of won deals out of closed deals.
+type winCount struct {
+	Won    int
+	Closed int
+}
+
+func (wc winCount) Rate() float64 {
+	if wc.Closed == 0 {
+		return 0
+	}
+	return float64(wc.Won) / float64(wc.Closed)
+}
+
+// Confidence is one minus the width of the 95% Wilson interval on the rate,
+// so it grows with the number of closed deals behind it.
+func (wc winCount) Confidence() float64 {
+	lower, upper := wilsonInterval(wc.Won, wc.Closed, winRateConfidence)
+	return 1 - (upper - lower)
+}
+
+// GetRecommendations returns the top Thresholds.MaxRecommendations open or accepted
+// recommendations, ranked by impact weighted by confidence. Dismissed and
+// done recommendations are left out so the next candidate takes their place.
+func (as *AnalyticsService) GetRecommendations(ctx context.Context) ([]models.Recommendation, error) {
+	return cached(ctx, as, "recommendations", as.recommendations)
+}
+
+func (as *AnalyticsService) recommendations(ctx context.Context) ([]models.Recommendation, error) {
+	as = as.scoped(ctx)
+	ranked, err := as.rankedRecommendations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := []models.Recommendation{}
+	for _, rec := range ranked {
+		rec.Status = models.RecommendationOpen
+		if fb, ok := as.Feedback.Get(rec.ID); ok {
+			rec.Status = fb.Status
+		}
+		if rec.Status == models.RecommendationDismissed || rec.Status == models.RecommendationDone {
+			continue
+		}
+		recommendations = append(recommendations, rec)
+	}
+
+	return recommendations[:min(as.Thresholds.MaxRecommendations, len(recommendations))], nil
+}
+
+// rankedRecommendations builds every candidate action, each with the dollar
+// value it is expected to recover and a confidence from the sample size
+// behind that estimate, and ranks them by impact weighted by confidence.
+// GapShare is the impact as a percentage of the remaining quarter gap (or of
+// the target once the gap is closed), capped at 100.
+func (as *AnalyticsService) rankedRecommendations(ctx context.Context) ([]models.Recommendation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	winRates := as.closedWinCounts()
+
+	candidates := []models.Recommendation{}
+	for _, build := range []func(context.Context, map[string]winCount) ([]models.Recommendation, error){
+		as.staleEnterpriseRecommendation,
+		as.coachingRecommendations,
+		as.activityRecommendation,
+		as.pipelineCoverageRecommendation,
+		as.fastTrackRecommendation,
+	} {
+		recs, err := build(ctx, winRates)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, recs...)
+	}
+
+	summary, err := as.GetSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	exposureBase, baseLabel := summary.Gap, "gap"
+	if summary.Gap <= 0 {
+		exposureBase, baseLabel = summary.Target, "target"
+	}
+	recommendations := []models.Recommendation{}
+	for _, rec := range candidates {
+		if rec.ImpactAmount <= 0 {
+			continue
+		}
+		rec.Score = rec.ImpactAmount * rec.Confidence
+		if exposureBase > 0 {
+			rec.GapShare = math.Min(rec.ImpactAmount/exposureBase, 1) * 100
+		}
+		rec.Priority = recommendationPriority(rec.GapShare)
+		rec.Impact = fmt.Sprintf("$%.0f expected impact (%.1f%% of %s), confidence %.0f%%", rec.ImpactAmount, rec.GapShare, baseLabel, rec.Confidence*100)
+		if rec.Evidence.DealIDs == nil {
+			rec.Evidence.DealIDs = []string{}
+		}
+		if rec.Evidence.RepIDs == nil {
+			rec.Evidence.RepIDs = []string{}
+		}
+		recommendations = append(recommendations, rec)
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+	return recommendations, nil
+}
+
+func recommendationPriority(gapShare float64) string {
+	switch {
+	case gapShare >= 15:
+		return "high"
+	case gapShare >= 5:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// closedWinCounts counts won and closed deals per segment, and overall under
+// AllSegments.
+func (as *AnalyticsService) closedWinCounts() map[string]winCount {
+	counts := make(map[string]winCount)
+	for _, deal := range as.DataService.Deals {
+		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
+			continue
+		}
+		keys := []string{AllSegments}
+		if account := as.DataService.GetAccountByID(deal.AccountID); account != nil {
+			keys = append(keys, account.Segment)
+		}
+		for _, key := range keys {
+			wc := counts[key]
+			wc.Closed++
+			if deal.Stage == "Closed Won" {
+				wc.Won++
+			}
+			counts[key] = wc
+		}
+	}
+	return counts
+}
+
+// segmentWinCount returns the segment's win count, or the overall one when
+// the segment has too few closed deals.
+func (as *AnalyticsService) segmentWinCount(counts map[string]winCount, segment string) winCount {
+	if wc, ok := counts[segment]; ok && wc.Closed >= as.Thresholds.MinSegmentSample {
+		return wc
+	}
+	return counts[AllSegments]
+}
+
+// quarterDealValue is the revenue the open deals are expected to add to the
+// current quarter: each deal's amount times its segment win rate times the
+// chance, from its segment's won-deal cycle lengths, that it closes before
+// the quarter ends (see CloseWindow). It returns the value with the deals
+// that can close in the quarter.
+func (as *AnalyticsService) quarterDealValue(deals []models.DealRiskItem, counts map[string]winCount) (float64, []models.DealRiskItem) {
+	from, end, ok := as.DataService.CloseWindow()
+	if !ok {
+		return 0, nil
+	}
+	// AgeDays of open deals is measured at the as-of date.
+	asOf := as.DataService.GetAsOfDate()
+	toFrom := int(from.Sub(asOf).Hours() / 24)
+	toEnd := int(end.Sub(asOf).Hours() / 24)
+	overall, bySegment := as.DataService.historicalDistributions(as.Thresholds.MinSegmentSample)
+
+	total := 0.0
+	closable := []models.DealRiskItem{}
+	for _, deal := range deals {
+		if !deal.IsOpen || deal.Amount == nil || deal.AgeDays == nil {
+			continue
+		}
+		distribution, ok := bySegment[deal.Segment]
+		if !ok {
+			distribution = overall
+		}
+		p := distribution.closeProbability(*deal.AgeDays+toFrom, *deal.AgeDays+toEnd)
+		if p == 0 {
+			continue
+		}
+		total += *deal.Amount * as.segmentWinCount(counts, deal.Segment).Rate() * p
+		closable = append(closable, deal)
+	}
+	return total, closable
+}
+
+// largestDealIDs returns the IDs of up to maxEvidenceDeals of the deals with
+// an amount, largest first.
+func largestDealIDs(deals []models.DealRiskItem) []string {
+	sorted := make([]models.DealRiskItem, 0, len(deals))
+	for _, deal := range deals {
+		if deal.Amount != nil {
+			sorted = append(sorted, deal)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return *sorted[i].Amount > *sorted[j].Amount
+	})
+
+	ids := []string{}
+	for _, deal := range sorted[:min(maxEvidenceDeals, len(sorted))] {
+		ids = append(ids, deal.DealID)
+	}
+	return ids
+}
+
+func (as *AnalyticsService) staleEnterpriseRecommendation(ctx context.Context, counts map[string]winCount) ([]models.Recommendation, error) {
+	rule, ok := as.RuleEngine.Rule("stale_enterprise_deals")
+	if !ok {
+		return nil, nil
+	}
+	_, data, err := as.EvaluateRule(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	dealData, ok := data.(models.DealRiskData)
+	if !ok {
+		return nil, nil
+	}
+	impact, closable := as.quarterDealValue(dealData.Deals, counts)
+	if len(closable) == 0 {
+		return nil, nil
+	}
+
+	action := fmt.Sprintf("Re-engage %d stale Enterprise deals", len(closable))
+	if days, ok := rule.Threshold("age_days"); ok {
+		action = fmt.Sprintf("Re-engage %d Enterprise deals older than %g days", len(closable), days)
+	}
+	return []models.Recommendation{{
+		ID:           "stale_enterprise_deals",
+		Type:         "stale_enterprise_deals",
+		Action:       action,
+		Description:  "Enterprise deals have the highest value but are moving slowly. Engage with decision-makers to accelerate closure.",
+		ImpactAmount: impact,
+		Confidence:   as.segmentWinCount(counts, "Enterprise").Confidence(),
+		Evidence:     models.RecommendationEvidence{Segment: "Enterprise", DealIDs: largestDealIDs(closable)},
+	}}, nil
+}
+
+// coachingRecommendations suggests coaching each underperforming rep; the
+// impact is the revenue they are expected to lose on their open pipeline by
+// converting below the team rate.
+func (as *AnalyticsService) coachingRecommendations(ctx context.Context, _ map[string]winCount) ([]models.Recommendation, error) {
+	rule, ok := as.RuleEngine.Rule("underperforming_reps")
+	if !ok {
+		return nil, nil
+	}
+	_, data, err := as.EvaluateRule(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	repData, ok := data.(models.RepRiskData)
+	if !ok {
+		return nil, nil
+	}
+	deals, err := as.dealRiskItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := []models.Recommendation{}
+	for _, rep := range repData.Reps {
+		openDeals := []models.DealRiskItem{}
+		for _, deal := range deals {
+			if deal.RepID == rep.RepID && deal.IsOpen {
+				openDeals = append(openDeals, deal)
+			}
+		}
+		recommendations = append(recommendations, models.Recommendation{
+			ID:           "coach_rep:" + rep.RepID,
+			Type:         "coach_rep",
+			Action:       fmt.Sprintf("Coach %s on win rate improvement", rep.RepName),
+			Description:  fmt.Sprintf("Win rate of %.1f%% against a team rate of %.1f%%. Provide training on objection handling and closing techniques.", rep.WinRate, rep.TeamWinRate),
+			ImpactAmount: rep.ExpectedRevenueLost,
+			Confidence:   winCount{Won: rep.WonDeals, Closed: rep.ClosedDeals}.Confidence(),
+			Evidence:     models.RecommendationEvidence{DealIDs: largestDealIDs(openDeals), RepIDs: []string{rep.RepID}},
+		})
+	}
+	return recommendations, nil
+}
+
+// activityRecommendation targets the segment with the fewest activities per
+// open deal. The impact is that segment's open pipeline without a demo times
+// the win rate lift historically seen on deals with a demo.
+func (as *AnalyticsService) activityRecommendation(ctx context.Context, _ map[string]winCount) ([]models.Recommendation, error) {
+	segment, err := as.findLowActivitySegment(ctx)
+	if err != nil || segment == "" {
+		return nil, err
+	}
+
+	allEffectiveness, err := as.GetActivityEffectiveness(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var effectiveness models.ActivityEffectiveness
+	for _, e := range allEffectiveness {
+		if e.Segment == segment {
+			effectiveness = e
+		}
+	}
+	lift := (effectiveness.WinRateWithDemo - effectiveness.WinRateWithoutDemo) / 100
+	if lift <= 0 {
+		return nil, nil
+	}
+
+	deals, err := as.dealRiskItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	withoutDemo := []models.DealRiskItem{}
+	pipeline := 0.0
+	for _, deal := range deals {
+		if !deal.IsOpen || deal.Segment != segment || deal.Amount == nil {
+			continue
+		}
+		activities, err := as.DataService.GetActivitiesByDealID(ctx, deal.DealID)
+		if err != nil {
+			return nil, err
+		}
+		hasDemo := false
+		for _, activity := range activities {
+			if activity.Type == "demo" {
+				hasDemo = true
+				break
+			}
+		}
+		if !hasDemo {
+			withoutDemo = append(withoutDemo, deal)
+			pipeline += *deal.Amount
+		}
+	}
+	wonWithDemo := int(effectiveness.WinRateWithDemo*float64(effectiveness.DealsWithDemo)/100 + 0.5)
+	return []models.Recommendation{{
+		ID:           "increase_activity:" + segment,
+		Type:         "increase_activity",
+		Action:       fmt.Sprintf("Schedule demos for %s deals without one", segment),
+		Description:  fmt.Sprintf("%s has the fewest activities per open deal, and its deals with a demo win %.1f points more often.", segment, lift*100),
+		ImpactAmount: pipeline * lift,
+		Confidence:   winCount{Won: wonWithDemo, Closed: effectiveness.DealsWithDemo}.Confidence(),
+		Evidence:     models.RecommendationEvidence{Segment: segment, DealIDs: largestDealIDs(withoutDemo)},
+	}}, nil
+}
+
+// pipelineCoverageRecommendation fires when open pipeline is below
+// Thresholds.PipelineCoverageTarget times next quarter's target; the impact is the
+// shortfall times the overall win rate.
+func (as *AnalyticsService) pipelineCoverageRecommendation(ctx context.Context, counts map[string]winCount) ([]models.Recommendation, error) {
+	quarter, year := as.DataService.GetCurrentQuarter()
+	nextQuarter, nextYear := quarter+1, year
+	if nextQuarter > 4 {
+		nextQuarter = 1
+		nextYear++
+	}
+
+	target := as.DataService.GetQuarterTarget(nextQuarter, nextYear)
+	if target <= 0 {
+		return nil, nil
+	}
+	openDeals, err := as.DataService.GetOpenDeals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pipeline := 0.0
+	for _, deal := range openDeals {
+		if deal.Amount != nil {
+			pipeline += *deal.Amount
+		}
+	}
+
+	shortfall := target*as.Thresholds.PipelineCoverageTarget - pipeline
+	if shortfall <= 0 {
+		return nil, nil
+	}
+
+	overall := counts[AllSegments]
+	return []models.Recommendation{{
+		ID:           "pipeline_coverage",
+		Type:         "pipeline_coverage",
+		Action:       fmt.Sprintf("Increase pipeline coverage - currently %.1fx of next quarter's target", pipeline/target),
+		Description:  fmt.Sprintf("Pipeline should be at least %.1fx of quarterly target for healthy conversion.", as.Thresholds.PipelineCoverageTarget),
+		ImpactAmount: shortfall * overall.Rate(),
+		Confidence:   overall.Confidence(),
+	}}, nil
+}
+
+func (as *AnalyticsService) fastTrackRecommendation(ctx context.Context, counts map[string]winCount) ([]models.Recommendation, error) {
+	deals, err := as.dealRiskItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	negotiation := []models.DealRiskItem{}
+	for _, deal := range deals {
+		if deal.Stage == "Negotiation" {
+			negotiation = append(negotiation, deal)
+		}
+	}
+	impact, closable := as.quarterDealValue(negotiation, counts)
+	if len(closable) == 0 {
+		return nil, nil
+	}
+
+	return []models.Recommendation{{
+		ID:           "fast_track_negotiation",
+		Type:         "fast_track_negotiation",
+		Action:       fmt.Sprintf("Fast-track %d deals in Negotiation stage that can close this quarter", len(closable)),
+		Description:  "Deals in negotiation are close to closure. Provide additional resources or executive support.",
+		ImpactAmount: impact,
+		Confidence:   counts[AllSegments].Confidence(),
+		Evidence:     models.RecommendationEvidence{DealIDs: largestDealIDs(closable)},
+	}}, nil
+}
+
+// findLowActivitySegment returns the segment with the fewest activities per
+// open deal, if that is below Thresholds.LowActivityPerDeal.
+func (as *AnalyticsService) findLowActivitySegment(ctx context.Context) (string, error) {
+	segmentActivity := make(map[string]struct {
+		TotalDeals    int
+		TotalActivity int
+	})
+
+	openDeals, err := as.DataService.GetOpenDeals(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, deal := range openDeals {
+		account := as.DataService.GetAccountByID(deal.AccountID)
+		if account != nil {
+			activityCount, err := as.DataService.GetActivityCount(ctx, deal.DealID)
+			if err != nil {
+				return "", err
+			}
+			stats := segmentActivity[account.Segment]
+			stats.TotalDeals++
+			stats.TotalActivity += activityCount
+			segmentActivity[account.Segment] = stats
+		}
+	}
+
+	lowestSegment := ""
+	lowestAvg := as.Thresholds.LowActivityPerDeal
+
+	// Segments are visited in name order so ties resolve the same way on
+	// every request.
+	for _, segment := range as.DataService.GetSegments() {
+		stats := segmentActivity[segment]
+		if stats.TotalDeals > 0 {
+			avg := float64(stats.TotalActivity) / float64(stats.TotalDeals)
+			if avg < lowestAvg {
+				lowestAvg = avg
+				lowestSegment = segment
+			}
+		}
+	}
+
+	return lowestSegment, nil
+}
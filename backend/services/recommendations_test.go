@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func recommendationsAt(t *testing.T, as *AnalyticsService, date string) map[string]float64 {
+	t.Helper()
+	now, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs, err := as.Snapshot(now).GetRecommendations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares := make(map[string]float64)
+	for _, rec := range recs {
+		shares[rec.ID] = rec.GapShare
+	}
+	return shares
+}
+
+// TestDealRecommendationsCountOnlyThisQuarter checks that fast-track and
+// stale Enterprise impacts come from deals that can still close in the
+// current quarter, so they neither saturate nor outlive the quarter.
+func TestDealRecommendationsCountOnlyThisQuarter(t *testing.T) {
+	as := newTestAnalyticsService(t)
+
+	during := recommendationsAt(t, as, "2025-11-15")
+	fastTrack, ok := during["fast_track_negotiation"]
+	if !ok {
+		t.Fatalf("no fast-track recommendation mid-quarter: %v", during)
+	}
+	stale, ok := during["stale_enterprise_deals"]
+	if !ok {
+		t.Fatalf("no stale Enterprise recommendation mid-quarter: %v", during)
+	}
+	if fastTrack >= 100 || stale >= 100 || fastTrack == stale {
+		t.Errorf("gap shares fast-track %v, stale Enterprise %v; want distinct and below 100", fastTrack, stale)
+	}
+
+	after := recommendationsAt(t, as, "2026-01-15")
+	for _, id := range []string{"fast_track_negotiation", "stale_enterprise_deals"} {
+		if share, ok := after[id]; ok {
+			t.Errorf("%s recommended after the quarter ended, gap share %v", id, share)
+		}
+	}
+}
+
+func TestCloseProbability(t *testing.T) {
+	d := outcomeDistribution{CycleLength: []int{10, 20, 30, 40}}
+	tests := []struct {
+		age, lastAge int
+		want         float64
+	}{
+		{0, 100, 1},
+		{0, 25, 0.5},
+		{25, 35, 0.5},
+		{35, 36, 0},
+		{41, 90, 0},
+	}
+	for _, tt := range tests {
+		if got := d.closeProbability(tt.age, tt.lastAge); got != tt.want {
+			t.Errorf("closeProbability(%d, %d) = %v, want %v", tt.age, tt.lastAge, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+	"revenue-intelligence-api/models"
+	"strings"
+)
+
+// ruleEntityFields lists the facts available to rules for each entity: the
+// JSON fields of its risk item.
+var ruleEntityFields = map[string][]string{
+	RuleEntityDeal:    jsonFieldNames(models.DealRiskItem{}),
+	RuleEntityRep:     jsonFieldNames(models.RepRiskItem{}),
+	RuleEntityAccount: jsonFieldNames(models.AccountRiskItem{}),
+}
+
+// riskValueFields names the field holding each entity's dollar value at
+// risk, which is summed into a risk's value and is its default sort.
+var riskValueFields = map[string]string{
+	RuleEntityDeal:    "amount",
+	RuleEntityRep:     "expected_revenue_lost",
+	RuleEntityAccount: "open_pipeline",
+}
+
+// EvaluateRule builds the risk items for the rule's entity, evaluates the
+// rule against them and returns the matching items as typed risk data.
+func (as *AnalyticsService) EvaluateRule(ctx context.Context, rule RiskRule) (RuleResult, models.RiskData, error) {
+	as = as.scoped(ctx)
+	switch rule.Entity {
+	case RuleEntityDeal:
+		items, err := as.dealRiskItems(ctx)
+		if err != nil {
+			return RuleResult{}, nil, err
+		}
+		result := rule.Evaluate(toFactsList(items), riskValueFields[rule.Entity])
+		return result, models.DealRiskData{
+			Kind:  models.RiskKindDeal,
+			Count: result.Count,
+			Deals: selectMatches(items, result.Matches),
+		}, nil
+	case RuleEntityRep:
+		items, err := as.repRiskItems(ctx)
+		if err != nil {
+			return RuleResult{}, nil, err
+		}
+		result := rule.Evaluate(toFactsList(items), riskValueFields[rule.Entity])
+		return result, models.RepRiskData{
+			Kind:  models.RiskKindRep,
+			Count: result.Count,
+			Reps:  selectMatches(items, result.Matches),
+		}, nil
+	default:
+		items, err := as.accountRiskItems(ctx)
+		if err != nil {
+			return RuleResult{}, nil, err
+		}
+		result := rule.Evaluate(toFactsList(items), riskValueFields[rule.Entity])
+		return result, models.AccountRiskData{
+			Kind:     models.RiskKindAccount,
+			Count:    result.Count,
+			Accounts: selectMatches(items, result.Matches),
+		}, nil
+	}
+}
+
+func isOpen(deal models.Deal) bool {
+	return deal.Stage != "Closed Won" && deal.Stage != "Closed Lost"
+}
+
+// dealRiskItems, repRiskItems and accountRiskItems return the risk items of
+// every entity, built once per Version and caller scope and shared by risk
+// factors, recommendations, next actions and outcomes. They must not be
+// modified.
+func (as *AnalyticsService) dealRiskItems(ctx context.Context) ([]models.DealRiskItem, error) {
+	return cached(ctx, as, "risk-items/deal", as.buildDealRiskItems)
+}
+
+func (as *AnalyticsService) repRiskItems(ctx context.Context) ([]models.RepRiskItem, error) {
+	return cached(ctx, as, "risk-items/rep", as.buildRepRiskItems)
+}
+
+func (as *AnalyticsService) accountRiskItems(ctx context.Context) ([]models.AccountRiskItem, error) {
+	return cached(ctx, as, "risk-items/account", as.buildAccountRiskItems)
+}
+
+func (as *AnalyticsService) buildDealRiskItems(ctx context.Context) ([]models.DealRiskItem, error) {
+	asOf := as.DataService.GetAsOfDate()
+	items := []models.DealRiskItem{}
+
+	for _, deal := range as.DataService.Deals {
+		activityCount, err := as.DataService.GetActivityCount(ctx, deal.DealID)
+		if err != nil {
+			return nil, err
+		}
+		item := models.DealRiskItem{
+			DealID:        deal.DealID,
+			AccountID:     deal.AccountID,
+			RepID:         deal.RepID,
+			Stage:         deal.Stage,
+			Amount:        deal.Amount,
+			IsOpen:        isOpen(deal),
+			ActivityCount: activityCount,
+		}
+		if account := as.DataService.GetAccountByID(deal.AccountID); account != nil {
+			item.AccountName = account.Name
+			item.Segment = account.Segment
+			item.Industry = account.Industry
+		}
+		if rep := as.DataService.GetRepByID(deal.RepID); rep != nil {
+			item.RepName = rep.Name
+		}
+
+		lastActivity, err := as.DataService.GetLastActivity(ctx, deal.DealID)
+		if err != nil {
+			return nil, err
+		}
+		if lastActivity != nil {
+			item.LastActivityDate = &lastActivity.Timestamp
+			item.LastActivityType = &lastActivity.Type
+		}
+
+		if created, err := as.DataService.ParseDate(deal.CreatedAt); err == nil {
+			age := int(asOf.Sub(created).Hours() / 24)
+			item.AgeDays = &age
+
+			// Deals with no activity count as last engaged when created.
+			lastEngagement := created
+			if lastActivity != nil {
+				if ts, err := as.DataService.ParseDate(lastActivity.Timestamp); err == nil && ts.After(created) {
+					lastEngagement = ts
+				}
+			}
+			sinceActivity := int(asOf.Sub(lastEngagement).Hours() / 24)
+			item.DaysSinceActivity = &sinceActivity
+		}
+
+		if deal.StageChangedAt != nil && *deal.StageChangedAt != "" {
+			if changed, err := as.DataService.ParseDate(*deal.StageChangedAt); err == nil {
+				inStage := int(asOf.Sub(changed).Hours() / 24)
+				item.DaysInStage = &inStage
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// winRateConfidence is the z-score of the 95% Wilson score interval used to
+// compare a rep's win rate with the team's.
+const winRateConfidence = 1.96
+
+// buildRepRiskItems computes each rep's win rate over closed deals (won / won+lost)
+// with a 95% Wilson score interval, the team's closed-deal win rate, and the
+// revenue the rep is expected to lose on their open pipeline by converting
+// below the team rate. The team rate is over every rep, even for a scoped
+// caller.
+func (as *AnalyticsService) buildRepRiskItems(ctx context.Context) ([]models.RepRiskItem, error) {
+	items, err := repWinRates(ctx, as.DataService)
+	if err != nil {
+		return nil, err
+	}
+	team := items
+	if company := as.DataService.Company(); company != as.DataService {
+		if team, err = repWinRates(ctx, company); err != nil {
+			return nil, err
+		}
+	}
+
+	teamWon, teamClosed := 0, 0
+	for _, item := range team {
+		teamWon += item.WonDeals
+		teamClosed += item.ClosedDeals
+	}
+	teamWinRate := 0.0
+	if teamClosed > 0 {
+		teamWinRate = (float64(teamWon) / float64(teamClosed)) * 100
+	}
+	for i := range items {
+		items[i].TeamWinRate = teamWinRate
+		if items[i].ClosedDeals > 0 && items[i].WinRate < teamWinRate {
+			items[i].ExpectedRevenueLost = (teamWinRate - items[i].WinRate) / 100 * items[i].OpenPipeline
+		}
+	}
+
+	return items, nil
+}
+
+// repWinRates counts each of ds's reps' deals by stage and computes their
+// closed-deal win rate and its interval.
+func repWinRates(ctx context.Context, ds *DataService) ([]models.RepRiskItem, error) {
+	items := []models.RepRiskItem{}
+	for _, rep := range ds.Reps {
+		item := models.RepRiskItem{RepID: rep.RepID, RepName: rep.Name}
+		deals, err := ds.GetDealsByRepID(ctx, rep.RepID)
+		if err != nil {
+			return nil, err
+		}
+		for _, deal := range deals {
+			item.TotalDeals++
+			switch deal.Stage {
+			case "Closed Won":
+				item.WonDeals++
+			case "Closed Lost":
+				item.LostDeals++
+			default:
+				item.OpenDeals++
+				if deal.Amount != nil {
+					item.OpenPipeline += *deal.Amount
+				}
+			}
+		}
+		item.ClosedDeals = item.WonDeals + item.LostDeals
+		if item.ClosedDeals > 0 {
+			item.WinRate = (float64(item.WonDeals) / float64(item.ClosedDeals)) * 100
+		}
+		lower, upper := wilsonInterval(item.WonDeals, item.ClosedDeals, winRateConfidence)
+		item.WinRateLower, item.WinRateUpper = lower*100, upper*100
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// wilsonInterval returns the Wilson score interval for successes out of n
+// trials as proportions. With no trials the interval is [0, 1].
+func wilsonInterval(successes, n int, z float64) (float64, float64) {
+	if n == 0 {
+		return 0, 1
+	}
+	p := float64(successes) / float64(n)
+	nf := float64(n)
+	denominator := 1 + z*z/nf
+	center := p + z*z/(2*nf)
+	margin := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf))
+	return math.Max(0, (center-margin)/denominator), math.Min(1, (center+margin)/denominator)
+}
+
+func (as *AnalyticsService) buildAccountRiskItems(ctx context.Context) ([]models.AccountRiskItem, error) {
+	items := []models.AccountRiskItem{}
+
+	for _, account := range as.DataService.Accounts {
+		item := models.AccountRiskItem{
+			AccountID:   account.AccountID,
+			AccountName: account.Name,
+			Segment:     account.Segment,
+			Industry:    account.Industry,
+		}
+		deals, err := as.DataService.GetDealsByAccountID(ctx, account.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		for _, deal := range deals {
+			if !isOpen(deal) {
+				continue
+			}
+			activityCount, err := as.DataService.GetActivityCount(ctx, deal.DealID)
+			if err != nil {
+				return nil, err
+			}
+			item.OpenDeals++
+			item.TotalActivities += activityCount
+			if deal.Amount != nil {
+				item.OpenPipeline += *deal.Amount
+			}
+		}
+		if item.OpenDeals > 0 {
+			item.AvgActivities = float64(item.TotalActivities) / float64(item.OpenDeals)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// toFactsList converts risk items to facts keyed by their JSON field names,
+// so rules refer to fields exactly as clients see them.
+func toFactsList[T any](items []T) []Facts {
+	facts := make([]Facts, 0, len(items))
+	for _, item := range items {
+		f := Facts{}
+		data, err := json.Marshal(item)
+		if err == nil {
+			json.Unmarshal(data, &f)
+		}
+		facts = append(facts, f)
+	}
+	return facts
+}
+
+func selectMatches[T any](items []T, matches []int) []T {
+	selected := make([]T, 0, len(matches))
+	for _, i := range matches {
+		selected = append(selected, items[i])
+	}
+	return selected
+}
+
+func jsonFieldNames(v interface{}) []string {
+	names := []string{}
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"revenue-intelligence-api/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultRiskPageSize = 25
+	MaxRiskPageSize     = 100
+)
+
+var ErrRiskTypeNotFound = errors.New("risk type not found")
+
+// RiskQuery selects, orders and pages the items of a risk. Sort and Filters
+// name fields of the entity's risk item. A filter on a numeric field matches
+// values that round to it at the precision it is written with, so "1500000"
+// matches 1.5e6 and "136753.75" matches 136753.7473; other fields match on
+// their text. Order is "asc" or "desc". MinAmount and MaxAmount bound the
+// entity's value at risk.
+type RiskQuery struct {
+	Sort      string
+	Order     string
+	Page      int
+	PageSize  int
+	Filters   map[string]string
+	MinAmount *float64
+	MaxAmount *float64
+}
+
+// DefaultQuery returns a query for the first pageSize matches in the rule's
+// default order.
+func (rule RiskRule) DefaultQuery(pageSize int) RiskQuery {
+	query := RiskQuery{Sort: rule.Sort, Order: rule.Order, Page: 1, PageSize: pageSize}
+	if query.Sort == "" {
+		query.Sort = riskValueFields[rule.Entity]
+	}
+	if query.Order == "" {
+		query.Order = "desc"
+	}
+	return query
+}
+
+// riskSortAliases maps friendly sort names to risk item fields per entity.
+// "amount" always sorts by the entity's value at risk.
+var riskSortAliases = map[string]map[string]string{
+	RuleEntityDeal: {
+		"age":        "age_days",
+		"activities": "activity_count",
+	},
+	RuleEntityAccount: {
+		"amount":         "open_pipeline",
+		"activity_count": "total_activities",
+		"activities":     "total_activities",
+	},
+	RuleEntityRep: {
+		"amount": "expected_revenue_lost",
+	},
+}
+
+// GetRiskFactorDetail returns every match of a non-internal risk rule,
+// filtered, sorted and paged by the query. Without a sort, the rule's default
+// ordering is used.
+func (as *AnalyticsService) GetRiskFactorDetail(ctx context.Context, riskType string, query RiskQuery) (models.RiskFactorDetail, error) {
+	return cached(ctx, as, "risk-factors/"+riskType+"?"+query.cacheKey(), func(ctx context.Context) (models.RiskFactorDetail, error) {
+		return as.riskFactorDetail(ctx, riskType, query)
+	})
+}
+
+func (as *AnalyticsService) riskFactorDetail(ctx context.Context, riskType string, query RiskQuery) (models.RiskFactorDetail, error) {
+	as = as.scoped(ctx)
+	rule, ok := as.RuleEngine.Rule(riskType)
+	if !ok || rule.Internal {
+		return models.RiskFactorDetail{}, ErrRiskTypeNotFound
+	}
+
+	defaults := rule.DefaultQuery(DefaultRiskPageSize)
+	if query.Sort == "" {
+		query.Sort = defaults.Sort
+		if query.Order == "" {
+			query.Order = defaults.Order
+		}
+	}
+	if query.Order == "" {
+		query.Order = "desc"
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = DefaultRiskPageSize
+	}
+	if query.PageSize > MaxRiskPageSize {
+		query.PageSize = MaxRiskPageSize
+	}
+
+	exposureBase, err := as.riskExposureBase(ctx)
+	if err != nil {
+		return models.RiskFactorDetail{}, err
+	}
+	risk, count, err := as.buildRiskFactor(ctx, rule, exposureBase)
+	if err != nil {
+		return models.RiskFactorDetail{}, err
+	}
+	data, total, err := applyRiskQuery(risk.Data, query)
+	if err != nil {
+		return models.RiskFactorDetail{}, err
+	}
+	risk.Data = data
+
+	return models.RiskFactorDetail{
+		RiskFactor: risk,
+		MatchCount: count,
+		Total:      total,
+		Page:       query.Page,
+		PageSize:   query.PageSize,
+		TotalPages: (total + query.PageSize - 1) / query.PageSize,
+		Sort:       query.Sort,
+		Order:      query.Order,
+	}, nil
+}
+
+// applyRiskQuery filters, sorts and pages the items in data. It returns the
+// new data, whose Count is the number of items after filtering, and that
+// number.
+func applyRiskQuery(data models.RiskData, query RiskQuery) (models.RiskData, int, error) {
+	var err error
+	total := 0
+	switch d := data.(type) {
+	case models.DealRiskData:
+		d.Deals, total, err = queryRiskItems(RuleEntityDeal, d.Deals, query)
+		d.Count = total
+		data = d
+	case models.RepRiskData:
+		d.Reps, total, err = queryRiskItems(RuleEntityRep, d.Reps, query)
+		d.Count = total
+		data = d
+	case models.AccountRiskData:
+		d.Accounts, total, err = queryRiskItems(RuleEntityAccount, d.Accounts, query)
+		d.Count = total
+		data = d
+	}
+	return data, total, err
+}
+
+func queryRiskItems[T any](entity string, items []T, query RiskQuery) ([]T, int, error) {
+	known := ruleEntityFields[entity]
+
+	sortField := query.Sort
+	if alias, ok := riskSortAliases[entity][sortField]; ok {
+		sortField = alias
+	}
+	if sortField != "" && !contains(known, sortField) {
+		return nil, 0, fmt.Errorf("unknown sort field %q", query.Sort)
+	}
+	for field := range query.Filters {
+		if !contains(known, field) {
+			return nil, 0, fmt.Errorf("unknown filter field %q", field)
+		}
+	}
+
+	filters := parseRiskFilters(query.Filters)
+	facts := toFactsList(items)
+	indexes := []int{}
+	for i, f := range facts {
+		if matchesRiskFilters(f, filters, query, riskValueFields[entity]) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	if sortField != "" {
+		// Items without a value sort last; ties keep their original order.
+		sort.SliceStable(indexes, func(a, b int) bool {
+			va, vb := facts[indexes[a]][sortField], facts[indexes[b]][sortField]
+			if va == nil || vb == nil {
+				return va != nil && vb == nil
+			}
+			if query.Order == "desc" {
+				return lessFact(vb, va)
+			}
+			return lessFact(va, vb)
+		})
+	}
+
+	// Pages past the end are empty; compare before multiplying so a huge
+	// page number cannot overflow.
+	total := len(indexes)
+	start := total
+	if query.Page-1 <= total/query.PageSize {
+		start = min((query.Page-1)*query.PageSize, total)
+	}
+	end := min(start+query.PageSize, total)
+
+	return selectMatches(items, indexes[start:end]), total, nil
+}
+
+// riskFilter is a parsed filter value. number is set when the value parses
+// as a number, with tolerance half a unit in its last written decimal.
+type riskFilter struct {
+	text      string
+	number    *float64
+	tolerance float64
+}
+
+func parseRiskFilters(filters map[string]string) map[string]riskFilter {
+	parsed := make(map[string]riskFilter, len(filters))
+	for field, text := range filters {
+		filter := riskFilter{text: text}
+		if n, err := strconv.ParseFloat(text, 64); err == nil {
+			filter.number = &n
+			if _, fraction, ok := strings.Cut(text, "."); ok && !strings.ContainsAny(text, "eE") {
+				filter.tolerance = 0.5 * math.Pow10(-len(fraction))
+			} else if !strings.ContainsAny(text, "eE") {
+				filter.tolerance = 0.5
+			}
+		}
+		parsed[field] = filter
+	}
+	return parsed
+}
+
+func (filter riskFilter) matches(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	if n, ok := toFloat(v); ok {
+		return filter.number != nil && math.Abs(n-*filter.number) <= filter.tolerance
+	}
+	return fmt.Sprint(v) == filter.text
+}
+
+func matchesRiskFilters(f Facts, filters map[string]riskFilter, query RiskQuery, valueField string) bool {
+	for field, filter := range filters {
+		if !filter.matches(f[field]) {
+			return false
+		}
+	}
+	if query.MinAmount != nil || query.MaxAmount != nil {
+		amount, ok := toFloat(f[valueField])
+		if !ok {
+			return false
+		}
+		if query.MinAmount != nil && amount < *query.MinAmount {
+			return false
+		}
+		if query.MaxAmount != nil && amount > *query.MaxAmount {
+			return false
+		}
+	}
+	return true
+}
+
+func lessFact(a, b interface{}) bool {
+	if af, ok := toFloat(a); ok {
+		bf, _ := toFloat(b)
+		return af < bf
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestRiskFilterMatches(t *testing.T) {
+	tests := []struct {
+		filter string
+		value  interface{}
+		want   bool
+	}{
+		{"1500000", 1500000.0, true},
+		{"1500000", 1500001.0, false},
+		{"1.5e6", 1500000.0, true},
+		{"136753.75", 136753.74730066443, true},
+		{"136753.7", 136753.74730066443, true},
+		{"136753.74", 136753.74730066443, false},
+		{"3", 3, true},
+		{"SMB", "SMB", true},
+		{"SMB", "Enterprise", false},
+		{"true", true, true},
+		{"abc", 3.0, false},
+		{"3", nil, false},
+	}
+	for _, tt := range tests {
+		filter := parseRiskFilters(map[string]string{"field": tt.filter})["field"]
+		if got := filter.matches(tt.value); got != tt.want {
+			t.Errorf("filter %q on %v = %v, want %v", tt.filter, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestQueryRiskItemsRejectsUnknownFilter(t *testing.T) {
+	query := RiskQuery{Page: 1, PageSize: 10, Filters: map[string]string{"foo": "1"}}
+	if _, _, err := queryRiskItems(RuleEntityRep, []struct{}{}, query); err == nil {
+		t.Error("unknown filter field accepted")
+	}
+}
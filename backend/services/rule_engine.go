@@ -0,0 +1,463 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"revenue-intelligence-api/models"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	RuleEntityDeal    = models.RiskKindDeal
+	RuleEntityRep     = models.RiskKindRep
+	RuleEntityAccount = models.RiskKindAccount
+)
+
+// Facts are the named values a rule's conditions are evaluated against for
+// one deal, rep or account.
+type Facts map[string]interface{}
+
+// RuleCondition is either a comparison of a fact against a value, or a
+// combination of nested conditions with all/any/not. When By is set, the
+// value is looked up in Values using the fact named by By (e.g. "segment"),
+// falling back to Value. When ValueField is set, the fact is compared with
+// another fact of the same entity instead.
+type RuleCondition struct {
+	All        []RuleCondition        `json:"all,omitempty"`
+	Any        []RuleCondition        `json:"any,omitempty"`
+	Not        *RuleCondition         `json:"not,omitempty"`
+	Field      string                 `json:"field,omitempty"`
+	Op         string                 `json:"op,omitempty"`
+	Value      interface{}            `json:"value,omitempty"`
+	ValueField string                 `json:"value_field,omitempty"`
+	By         string                 `json:"by,omitempty"`
+	Values     map[string]interface{} `json:"values,omitempty"`
+}
+
+// RiskRule declares one risk type. Internal rules are evaluated on request
+// (e.g. by recommendations) but not reported as risk factors. Severity, when
+// set, is the minimum severity reported regardless of score. Sort and Order
+// set the default ordering of matches (value at risk, descending if unset).
+type RiskRule struct {
+	Type        string        `json:"type"`
+	Entity      string        `json:"entity"`
+	Severity    string        `json:"severity,omitempty"`
+	Description string        `json:"description"`
+	When        RuleCondition `json:"when"`
+	Sort        string        `json:"sort,omitempty"`
+	Order       string        `json:"order,omitempty"`
+	Limit       int           `json:"limit,omitempty"`
+	Internal    bool          `json:"internal,omitempty"`
+
+	descriptionTemplate *template.Template
+}
+
+// SeverityBand maps a minimum risk score to a severity.
+type SeverityBand struct {
+	Severity string  `json:"severity"`
+	MinScore float64 `json:"min_score"`
+}
+
+type RuleSet struct {
+	SeverityBands []SeverityBand `json:"severity_bands,omitempty"`
+	Rules         []RiskRule     `json:"rules"`
+}
+
+var DefaultSeverityBands = []SeverityBand{
+	{Severity: "critical", MinScore: 40},
+	{Severity: "high", MinScore: 15},
+	{Severity: "medium", MinScore: 5},
+	{Severity: "low", MinScore: 0},
+}
+
+// RuleResult is the outcome of evaluating one rule; it is also the data
+// passed to the rule's description template. Matches holds the indexes of
+// the matching candidates.
+type RuleResult struct {
+	Rule        *RiskRule
+	Count       int
+	TotalAmount float64
+	Matches     []int
+}
+
+var ruleOperators = []string{"eq", "ne", "gt", "gte", "lt", "lte", "in", "exists"}
+var ruleSeverities = []string{"low", "medium", "high", "critical"}
+
+// RuleEngine holds the rule set loaded from a JSON file. It is safe for
+// concurrent use, and Reload swaps in a new rule set only if it is valid.
+type RuleEngine struct {
+	path  string
+	mu    sync.RWMutex
+	rules []RiskRule
+	bands []SeverityBand
+	stats RuleLoadStats
+}
+
+// RuleLoadStats counts the rule engine's loads, including the initial one.
+type RuleLoadStats struct {
+	Loads        int
+	Failures     int
+	LastDuration time.Duration
+
+	// LoadedAt is the time of the last successful load.
+	LoadedAt time.Time
+}
+
+func NewRuleEngine(path string) (*RuleEngine, error) {
+	re := &RuleEngine{path: path}
+	if err := re.Reload(); err != nil {
+		return nil, err
+	}
+	return re, nil
+}
+
+// Reload reads the rule file again and swaps in its rules if they are valid.
+func (re *RuleEngine) Reload() error {
+	start := time.Now()
+	err := re.load()
+
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.stats.Loads++
+	re.stats.LastDuration = time.Since(start)
+	if err != nil {
+		re.stats.Failures++
+	} else {
+		re.stats.LoadedAt = time.Now()
+	}
+	return err
+}
+
+// Snapshot returns a copy of the engine holding the current rules, which
+// later reloads of the engine do not change.
+func (re *RuleEngine) Snapshot() *RuleEngine {
+	re.mu.RLock()
+	defer re.mu.RUnlock()
+	return &RuleEngine{path: re.path, rules: re.rules, bands: re.bands, stats: re.stats}
+}
+
+// Version counts successful loads, so it changes whenever the rules may
+// have.
+func (re *RuleEngine) Version() int {
+	re.mu.RLock()
+	defer re.mu.RUnlock()
+	return re.stats.Loads - re.stats.Failures
+}
+
+func (re *RuleEngine) LoadStats() RuleLoadStats {
+	re.mu.RLock()
+	defer re.mu.RUnlock()
+	return re.stats
+}
+
+func (re *RuleEngine) load() error {
+	data, err := os.ReadFile(re.path)
+	if err != nil {
+		return err
+	}
+
+	var set RuleSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("parse %s: %w", re.path, err)
+	}
+
+	if len(set.SeverityBands) == 0 {
+		set.SeverityBands = DefaultSeverityBands
+	}
+	for i, band := range set.SeverityBands {
+		if !contains(ruleSeverities, band.Severity) {
+			return fmt.Errorf("severity band %d: unknown severity %q", i, band.Severity)
+		}
+	}
+	sort.SliceStable(set.SeverityBands, func(i, j int) bool {
+		return set.SeverityBands[i].MinScore > set.SeverityBands[j].MinScore
+	})
+
+	seen := make(map[string]bool)
+	for i := range set.Rules {
+		rule := &set.Rules[i]
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("rule %d (%s): %w", i, rule.Type, err)
+		}
+		if seen[rule.Type] {
+			return fmt.Errorf("rule %d: duplicate type %q", i, rule.Type)
+		}
+		seen[rule.Type] = true
+	}
+
+	re.mu.Lock()
+	re.rules = set.Rules
+	re.bands = set.SeverityBands
+	re.mu.Unlock()
+	return nil
+}
+
+func (re *RuleEngine) Rules() []RiskRule {
+	re.mu.RLock()
+	defer re.mu.RUnlock()
+	return re.rules
+}
+
+func (re *RuleEngine) SeverityBands() []SeverityBand {
+	re.mu.RLock()
+	defer re.mu.RUnlock()
+	return re.bands
+}
+
+// Severity returns the severity of the highest band the score reaches,
+// raised to minimum if that is more severe.
+func (re *RuleEngine) Severity(score float64, minimum string) string {
+	severity := "low"
+	for _, band := range re.SeverityBands() {
+		if score >= band.MinScore {
+			severity = band.Severity
+			break
+		}
+	}
+	if minimum != "" && severityRank(minimum) > severityRank(severity) {
+		return minimum
+	}
+	return severity
+}
+
+func severityRank(severity string) int {
+	for i, s := range ruleSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+func (re *RuleEngine) Rule(ruleType string) (RiskRule, bool) {
+	for _, rule := range re.Rules() {
+		if rule.Type == ruleType {
+			return rule, true
+		}
+	}
+	return RiskRule{}, false
+}
+
+func (rule *RiskRule) validate() error {
+	if rule.Type == "" {
+		return fmt.Errorf("missing type")
+	}
+	known, ok := ruleEntityFields[rule.Entity]
+	if !ok {
+		return fmt.Errorf("unknown entity %q", rule.Entity)
+	}
+	if rule.Severity != "" && !contains(ruleSeverities, rule.Severity) {
+		return fmt.Errorf("unknown severity %q", rule.Severity)
+	}
+	if err := rule.When.validate(known); err != nil {
+		return err
+	}
+	if rule.Sort != "" && !contains(known, rule.Sort) {
+		return fmt.Errorf("unknown sort field %q", rule.Sort)
+	}
+	if rule.Order != "" && rule.Order != "asc" && rule.Order != "desc" {
+		return fmt.Errorf("unknown order %q", rule.Order)
+	}
+
+	tmpl, err := template.New(rule.Type).Option("missingkey=error").Parse(rule.Description)
+	if err != nil {
+		return fmt.Errorf("description: %w", err)
+	}
+	rule.descriptionTemplate = tmpl
+	return nil
+}
+
+func (c *RuleCondition) validate(known []string) error {
+	nested := len(c.All) + len(c.Any)
+	if c.Not != nil {
+		nested++
+	}
+	if nested > 0 {
+		if c.Field != "" {
+			return fmt.Errorf("condition mixes field %q with all/any/not", c.Field)
+		}
+		for i := range c.All {
+			if err := c.All[i].validate(known); err != nil {
+				return err
+			}
+		}
+		for i := range c.Any {
+			if err := c.Any[i].validate(known); err != nil {
+				return err
+			}
+		}
+		if c.Not != nil {
+			return c.Not.validate(known)
+		}
+		return nil
+	}
+
+	if !contains(known, c.Field) {
+		return fmt.Errorf("unknown field %q", c.Field)
+	}
+	if !contains(ruleOperators, c.Op) {
+		return fmt.Errorf("unknown operator %q", c.Op)
+	}
+	if c.By != "" && !contains(known, c.By) {
+		return fmt.Errorf("unknown field %q in by", c.By)
+	}
+	if c.ValueField != "" && !contains(known, c.ValueField) {
+		return fmt.Errorf("unknown field %q in value_field", c.ValueField)
+	}
+	return nil
+}
+
+// Evaluate applies the rule to every candidate, in order, summing valueField
+// of the matches into TotalAmount.
+func (rule RiskRule) Evaluate(candidates []Facts, valueField string) RuleResult {
+	result := RuleResult{Rule: &rule, Matches: []int{}}
+	for i, facts := range candidates {
+		if !rule.When.matches(facts) {
+			continue
+		}
+		result.Count++
+		if amount, ok := toFloat(facts[valueField]); ok {
+			result.TotalAmount += amount
+		}
+		result.Matches = append(result.Matches, i)
+	}
+	return result
+}
+
+// Threshold returns the number the rule compares field with, from the first
+// condition on field that every match must meet.
+func (rule RiskRule) Threshold(field string) (float64, bool) {
+	return rule.When.threshold(field)
+}
+
+func (c RuleCondition) threshold(field string) (float64, bool) {
+	if c.Field == field && c.ValueField == "" {
+		return toFloat(c.Value)
+	}
+	for _, sub := range c.All {
+		if value, ok := sub.threshold(field); ok {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+// Describe renders the rule's description template for the result.
+func (result RuleResult) Describe() string {
+	if result.Rule.descriptionTemplate == nil {
+		return result.Rule.Description
+	}
+	var buf bytes.Buffer
+	if err := result.Rule.descriptionTemplate.Execute(&buf, result); err != nil {
+		return result.Rule.Description
+	}
+	return buf.String()
+}
+
+func (c RuleCondition) matches(facts Facts) bool {
+	if len(c.All) > 0 || len(c.Any) > 0 || c.Not != nil {
+		for _, sub := range c.All {
+			if !sub.matches(facts) {
+				return false
+			}
+		}
+		if len(c.Any) > 0 {
+			matched := false
+			for _, sub := range c.Any {
+				if sub.matches(facts) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		}
+		if c.Not != nil && c.Not.matches(facts) {
+			return false
+		}
+		return true
+	}
+
+	actual := facts[c.Field]
+	expected := c.Value
+	if c.ValueField != "" {
+		expected = facts[c.ValueField]
+	} else if c.By != "" {
+		if key, ok := facts[c.By].(string); ok {
+			if v, ok := c.Values[key]; ok {
+				expected = v
+			}
+		}
+	}
+
+	switch c.Op {
+	case "exists":
+		want, _ := expected.(bool)
+		return (actual != nil) == want
+	case "in":
+		list, _ := expected.([]interface{})
+		for _, v := range list {
+			if valuesEqual(actual, v) {
+				return true
+			}
+		}
+		return false
+	case "eq":
+		return valuesEqual(actual, expected)
+	case "ne":
+		return !valuesEqual(actual, expected)
+	}
+
+	a, ok := toFloat(actual)
+	if !ok {
+		return false
+	}
+	b, ok := toFloat(expected)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case "gt":
+		return a > b
+	case "gte":
+		return a >= b
+	case "lt":
+		return a < b
+	case "lte":
+		return a <= b
+	}
+	return false
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		return ok && af == bf
+	}
+	if as, ok := a.(string); ok {
+		bs, ok := b.(string)
+		return ok && as == bs
+	}
+	return a == b
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case *float64:
+		if n == nil {
+			return 0, false
+		}
+		return *n, true
+	}
+	return 0, false
+}
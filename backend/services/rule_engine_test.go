@@ -0,0 +1,18 @@
+package services
+
+import "testing"
+
+func TestRiskRuleThreshold(t *testing.T) {
+	rule := RiskRule{When: RuleCondition{All: []RuleCondition{
+		{Field: "is_open", Op: "eq", Value: true},
+		{Any: []RuleCondition{{Field: "age_days", Op: "gt", Value: 90.0}}},
+		{Field: "age_days", Op: "gt", ValueField: "cycle_days"},
+		{Field: "age_days", Op: "gt", Value: 45.0},
+	}}}
+	if got, ok := rule.Threshold("age_days"); !ok || got != 45 {
+		t.Errorf("Threshold(age_days) = %v, %v; want 45, true", got, ok)
+	}
+	if _, ok := rule.Threshold("amount"); ok {
+		t.Error("Threshold(amount) found a value for a field without a condition")
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"revenue-intelligence-api/models"
+)
+
+// ErrForbidden is returned for operations the caller's role may not perform.
+var ErrForbidden = errors.New("not permitted for this role")
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p models.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the caller, if the request was authenticated.
+// Without one, as when authentication is disabled, nothing is restricted.
+func PrincipalFromContext(ctx context.Context) (models.Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(models.Principal)
+	return p, ok
+}
+
+// RequireRole returns ErrForbidden unless the caller has one of roles or
+// there is no authenticated caller.
+func RequireRole(ctx context.Context, roles ...string) error {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	for _, role := range roles {
+		if p.Role == role {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// ForContext returns the dataset the context's caller may see: for managers
+// and reps only their reps, those reps' deals and the deals' accounts and
+// activities. Targets are company-wide, so they are scaled by the permitted
+// reps' share of all reps. The result of ForContext is never narrowed again.
+func (ds *DataService) ForContext(ctx context.Context) *DataService {
+	p, ok := PrincipalFromContext(ctx)
+	if ds.company != nil || !ok || p.Role == models.RoleCRO {
+		return ds
+	}
+
+	reps := make(map[string]bool, len(p.RepIDs))
+	for _, id := range p.RepIDs {
+		reps[id] = true
+	}
+
+	scoped := *ds
+	scoped.Reps, scoped.Deals, scoped.Accounts, scoped.Activities, scoped.Targets = nil, nil, nil, nil, nil
+	scoped.company = ds
+	for _, rep := range ds.Reps {
+		if reps[rep.RepID] {
+			scoped.Reps = append(scoped.Reps, rep)
+		}
+	}
+	if len(ds.Reps) > 0 {
+		share := float64(len(scoped.Reps)) / float64(len(ds.Reps))
+		for _, target := range ds.Targets {
+			scoped.Targets = append(scoped.Targets, models.Target{Month: target.Month, Target: target.Target * share})
+		}
+	}
+	deals := make(map[string]bool)
+	accounts := make(map[string]bool)
+	for _, deal := range ds.Deals {
+		if reps[deal.RepID] {
+			scoped.Deals = append(scoped.Deals, deal)
+			deals[deal.DealID] = true
+			accounts[deal.AccountID] = true
+		}
+	}
+	for _, account := range ds.Accounts {
+		if accounts[account.AccountID] {
+			scoped.Accounts = append(scoped.Accounts, account)
+		}
+	}
+	for _, activity := range ds.Activities {
+		if deals[activity.DealID] {
+			scoped.Activities = append(scoped.Activities, activity)
+		}
+	}
+	return &scoped
+}
+
+// scoped returns the service computing over the data the context's caller
+// may see. Every exported method starts from it, so aggregates only ever
+// include permitted deals.
+func (as *AnalyticsService) scoped(ctx context.Context) *AnalyticsService {
+	ds := as.DataService.ForContext(ctx)
+	if ds == as.DataService {
+		return as
+	}
+	scoped := *as
+	scoped.DataService = ds
+	return &scoped
+}
+
+func (ss *SimulationService) scoped(ctx context.Context) *SimulationService {
+	ds := ss.DataService.ForContext(ctx)
+	if ds == ss.DataService {
+		return ss
+	}
+	scoped := *ss
+	scoped.DataService = ds
+	return &scoped
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"revenue-intelligence-api/models"
+	"testing"
+)
+
+func loadTestData(t *testing.T) *DataService {
+	t.Helper()
+	ds, err := NewDataService(filepath.Join("..", "..", "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func newTestAnalyticsService(t *testing.T) *AnalyticsService {
+	t.Helper()
+	re, err := NewRuleEngine(filepath.Join("..", "..", "data", "risk_rules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewRecommendationStore(filepath.Join(t.TempDir(), "feedback.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAnalyticsService(loadTestData(t), re, store)
+}
+
+func repContext(repIDs ...string) context.Context {
+	return WithPrincipal(context.Background(), models.Principal{Subject: "test", Role: models.RoleRep, RepIDs: repIDs})
+}
+
+func TestForContextScalesTargets(t *testing.T) {
+	ds := loadTestData(t)
+	scoped := ds.ForContext(repContext("R2"))
+
+	quarter, year := ds.GetCurrentQuarter()
+	want := ds.GetQuarterTarget(quarter, year) / float64(len(ds.Reps))
+	if got := scoped.GetQuarterTarget(quarter, year); math.Abs(got-want) > 1e-6 {
+		t.Errorf("scoped quarter target = %v, want %v", got, want)
+	}
+	if got := ds.ForContext(context.Background()); got != ds {
+		t.Error("unauthenticated context was scoped")
+	}
+}
+
+func TestScopedTeamWinRateCoversAllReps(t *testing.T) {
+	as := newTestAnalyticsService(t)
+
+	all, err := as.repRiskItems(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := repContext("R2")
+	scoped, err := as.scoped(ctx).repRiskItems(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scoped) != 1 || scoped[0].RepID != "R2" {
+		t.Fatalf("scoped reps = %+v, want only R2", scoped)
+	}
+	if scoped[0].TeamWinRate != all[0].TeamWinRate {
+		t.Errorf("scoped team win rate = %v, want the company rate %v", scoped[0].TeamWinRate, all[0].TeamWinRate)
+	}
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"math/rand/v2"
+	"revenue-intelligence-api/models"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultSimulationIterations = 10000
+	MaxSimulationIterations     = 100000
+	DefaultSimulationSeed       = 1
+)
+
+type SimulationService struct {
+	DataService *DataService
+
+	// Segments with fewer closed deals than MinSegmentSample fall back to
+	// the distribution built from all deals.
+	MinSegmentSample int
+}
+
+func NewSimulationService(ds *DataService) *SimulationService {
+	return &SimulationService{
+		DataService:      ds,
+		MinSegmentSample: DefaultThresholds.MinSegmentSample,
+	}
+}
+
+// outcomeDistribution holds the historical win probability and the sales
+// cycle lengths (in days) of won deals for one segment.
+type outcomeDistribution struct {
+	WinRate     float64
+	CycleLength []int
+}
+
+type simulatedDeal struct {
+	Amount       float64
+	CreatedAt    time.Time
+	Distribution outcomeDistribution
+}
+
+// SimulateQuarter runs a Monte Carlo simulation of the current quarter.
+// Each open deal is sampled as won or not using its segment's historical
+// win rate, and given a close date by sampling a won-deal cycle length no
+// shorter than the time it has been open at the as-of date (see
+// GetAsOfDate); only deals closing between then and the quarter end add
+// their amount. Booked revenue is added to every run. Once the quarter has
+// ended no open deal can close in it, so no deals are sampled, every run is
+// the booked revenue and the result is flagged QuarterEnded. The simulation
+// stops with the context's error once it is done.
+func (ss *SimulationService) SimulateQuarter(ctx context.Context, iterations int, seed int64) (models.SimulationResult, error) {
+	ss = ss.scoped(ctx)
+	quarter, year := ss.DataService.GetCurrentQuarter()
+	from, quarterEnd, open := ss.DataService.CloseWindow()
+	booked, err := ss.DataService.GetQuarterRevenue(ctx, quarter, year)
+	if err != nil {
+		return models.SimulationResult{}, err
+	}
+	target := ss.DataService.GetQuarterTarget(quarter, year)
+
+	deals := []simulatedDeal{}
+	if open {
+		if deals, err = ss.buildSimulatedDeals(ctx); err != nil {
+			return models.SimulationResult{}, err
+		}
+	}
+	rng := rand.New(rand.NewPCG(uint64(seed), uint64(seed)))
+
+	outcomes := make([]float64, iterations)
+	hits := 0
+	total := 0.0
+	for i := 0; i < iterations; i++ {
+		if err := ctx.Err(); err != nil {
+			return models.SimulationResult{}, err
+		}
+		revenue := booked
+		for _, deal := range deals {
+			if rng.Float64() >= deal.Distribution.WinRate {
+				continue
+			}
+			closeDate, ok := sampleCloseDate(rng, deal, from)
+			if ok && !closeDate.Before(from) && !closeDate.After(quarterEnd) {
+				revenue += deal.Amount
+			}
+		}
+		outcomes[i] = revenue
+		total += revenue
+		if revenue >= target {
+			hits++
+		}
+	}
+
+	sort.Float64s(outcomes)
+
+	result := models.SimulationResult{
+		CurrentQuarter:     quarter,
+		CurrentQuarterYear: year,
+		Iterations:         iterations,
+		Seed:               seed,
+		BookedRevenue:      booked,
+		Target:             target,
+		OpenDealsSimulated: len(deals),
+		QuarterEnded:       !open,
+	}
+	if iterations > 0 {
+		result.ProbabilityOfTarget = float64(hits) / float64(iterations)
+		result.MeanRevenue = total / float64(iterations)
+		result.P10Revenue = percentile(outcomes, 10)
+		result.P50Revenue = percentile(outcomes, 50)
+		result.P90Revenue = percentile(outcomes, 90)
+	}
+
+	return result, nil
+}
+
+func (ss *SimulationService) buildSimulatedDeals(ctx context.Context) ([]simulatedDeal, error) {
+	overall, bySegment := ss.DataService.historicalDistributions(ss.MinSegmentSample)
+
+	openDeals, err := ss.DataService.GetOpenDeals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deals := []simulatedDeal{}
+	for _, deal := range openDeals {
+		if deal.Amount == nil {
+			continue
+		}
+		created, err := ss.DataService.ParseDate(deal.CreatedAt)
+		if err != nil {
+			continue
+		}
+
+		distribution := overall
+		account := ss.DataService.GetAccountByID(deal.AccountID)
+		if account != nil {
+			if segmentDist, ok := bySegment[account.Segment]; ok {
+				distribution = segmentDist
+			}
+		}
+
+		deals = append(deals, simulatedDeal{
+			Amount:       *deal.Amount,
+			CreatedAt:    created,
+			Distribution: distribution,
+		})
+	}
+
+	return deals, nil
+}
+
+// historicalDistributions derives win rates from closed deals (won / won+lost)
+// and cycle lengths from won deals with a usable closed_at date, both overall
+// and per account segment. Segments with fewer than minSample closed deals
+// are left out, so their deals use the overall distribution.
+func (ds *DataService) historicalDistributions(minSample int) (outcomeDistribution, map[string]outcomeDistribution) {
+	type counts struct {
+		Won    int
+		Lost   int
+		Cycles []int
+	}
+
+	overall := counts{}
+	segments := make(map[string]*counts)
+
+	for _, deal := range ds.Deals {
+		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
+			continue
+		}
+
+		segment := ""
+		if account := ds.GetAccountByID(deal.AccountID); account != nil {
+			segment = account.Segment
+		}
+		if segments[segment] == nil {
+			segments[segment] = &counts{}
+		}
+		stats := segments[segment]
+
+		if deal.Stage == "Closed Lost" {
+			overall.Lost++
+			stats.Lost++
+			continue
+		}
+
+		overall.Won++
+		stats.Won++
+		if deal.ClosedAt != nil && *deal.ClosedAt != "" {
+			age := ds.GetDealAge(deal)
+			if age >= 0 {
+				overall.Cycles = append(overall.Cycles, age)
+				stats.Cycles = append(stats.Cycles, age)
+			}
+		}
+	}
+
+	toDistribution := func(c counts, fallbackCycles []int) outcomeDistribution {
+		dist := outcomeDistribution{CycleLength: c.Cycles}
+		if c.Won+c.Lost > 0 {
+			dist.WinRate = float64(c.Won) / float64(c.Won+c.Lost)
+		}
+		if len(dist.CycleLength) == 0 {
+			dist.CycleLength = fallbackCycles
+		}
+		sort.Ints(dist.CycleLength)
+		return dist
+	}
+
+	overallDist := toDistribution(overall, nil)
+	bySegment := make(map[string]outcomeDistribution)
+	for segment, stats := range segments {
+		if segment == "" || stats.Won+stats.Lost < minSample {
+			continue
+		}
+		bySegment[segment] = toDistribution(*stats, overallDist.CycleLength)
+	}
+
+	return overallDist, bySegment
+}
+
+// sampleCloseDate draws a cycle length from the deal's distribution,
+// conditioned on the deal still being open at from. Deals already older
+// than every historical won cycle have no plausible close date and are
+// reported as not closing.
+func sampleCloseDate(rng *rand.Rand, deal simulatedDeal, from time.Time) (time.Time, bool) {
+	elapsed := 0
+	if deal.CreatedAt.Before(from) {
+		elapsed = int(from.Sub(deal.CreatedAt).Hours() / 24)
+	}
+
+	cycles := deal.Distribution.CycleLength
+	idx := sort.SearchInts(cycles, elapsed)
+	if idx >= len(cycles) {
+		return time.Time{}, false
+	}
+
+	cycle := cycles[idx+rng.IntN(len(cycles)-idx)]
+	return deal.CreatedAt.AddDate(0, 0, cycle), true
+}
+
+// closeProbability is the chance that a deal open at age days, if won,
+// closes by lastAge days: the share of won cycle lengths no shorter than age
+// that are at most lastAge.
+func (d outcomeDistribution) closeProbability(age, lastAge int) float64 {
+	cycles := d.CycleLength
+	first := sort.SearchInts(cycles, age)
+	if first >= len(cycles) {
+		return 0
+	}
+	closing := sort.SearchInts(cycles, lastAge+1) - first
+	return float64(max(closing, 0)) / float64(len(cycles)-first)
+}
+
+// percentile returns the nearest-rank percentile of an ascending slice.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(float64(len(sorted))*p/100+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
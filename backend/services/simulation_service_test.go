@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestSimulateQuarterFlagsEndedQuarter checks that open deals are simulated
+// while the quarter is running and that an ended quarter is flagged with the
+// booked revenue as its only outcome.
+func TestSimulateQuarterFlagsEndedQuarter(t *testing.T) {
+	ds := loadTestData(t)
+
+	during, err := NewSimulationService(ds.At(time.Date(2025, 11, 15, 0, 0, 0, 0, time.UTC))).SimulateQuarter(context.Background(), 2000, DefaultSimulationSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if during.QuarterEnded || during.OpenDealsSimulated == 0 {
+		t.Fatalf("mid-quarter: quarter_ended = %v with %d open deals, want a running quarter with open deals", during.QuarterEnded, during.OpenDealsSimulated)
+	}
+	if !(during.P10Revenue < during.P90Revenue) {
+		t.Errorf("mid-quarter outcomes do not vary: p10 %v, p90 %v", during.P10Revenue, during.P90Revenue)
+	}
+	if during.P10Revenue < during.BookedRevenue {
+		t.Errorf("p10 %v is below the booked revenue %v", during.P10Revenue, during.BookedRevenue)
+	}
+
+	after, err := NewSimulationService(ds.At(time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC))).SimulateQuarter(context.Background(), 2000, DefaultSimulationSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.QuarterEnded || after.OpenDealsSimulated != 0 {
+		t.Fatalf("after the quarter: quarter_ended = %v with %d open deals, want it flagged with none", after.QuarterEnded, after.OpenDealsSimulated)
+	}
+	for _, v := range []float64{after.MeanRevenue, after.P10Revenue, after.P50Revenue, after.P90Revenue} {
+		if v != after.BookedRevenue {
+			t.Errorf("ended quarter outcome %v, want the booked revenue %v", v, after.BookedRevenue)
+		}
+	}
+}
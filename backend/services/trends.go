@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"revenue-intelligence-api/models"
+	"time"
+)
+
+var TrendMetrics = []string{"revenue", "win_rate", "avg_deal_size", "cycle_time", "pipeline", "activities"}
+var TrendGranularities = []string{"week", "month", "quarter"}
+
+type trendBucket struct {
+	Start time.Time
+	End   time.Time
+	Sum   float64
+	Count int
+	Won   int
+}
+
+// GetTrends returns a time series for the given metric, bucketed by week
+// (ISO, starting Monday), month or quarter. Closing metrics are bucketed by
+// closed_at, activities by timestamp, and pipeline is the open pipeline value
+// at the end of each bucket. Revenue and pipeline include a target series.
+func (as *AnalyticsService) GetTrends(ctx context.Context, metric, granularity string) (models.TrendResponse, error) {
+	return cached(ctx, as, "trends?metric="+metric+"&granularity="+granularity, func(ctx context.Context) (models.TrendResponse, error) {
+		return as.trends(ctx, metric, granularity)
+	})
+}
+
+func (as *AnalyticsService) trends(ctx context.Context, metric, granularity string) (models.TrendResponse, error) {
+	as = as.scoped(ctx)
+	if err := ctx.Err(); err != nil {
+		return models.TrendResponse{}, err
+	}
+	if !contains(TrendMetrics, metric) {
+		return models.TrendResponse{}, fmt.Errorf("unknown metric %q", metric)
+	}
+	if !contains(TrendGranularities, granularity) {
+		return models.TrendResponse{}, fmt.Errorf("unknown granularity %q", granularity)
+	}
+
+	response := models.TrendResponse{
+		Metric:      metric,
+		Granularity: granularity,
+		Series:      []models.TrendPoint{},
+	}
+	cal := as.DataService.Calendar
+
+	var buckets []*trendBucket
+	switch metric {
+	case "revenue", "avg_deal_size", "cycle_time", "win_rate":
+		buckets = as.closingTrend(metric, granularity)
+	case "pipeline":
+		buckets = as.pipelineTrend(granularity)
+	case "activities":
+		buckets = as.activityTrend(granularity)
+	}
+
+	for _, b := range buckets {
+		point := newTrendPoint(b, granularity, cal)
+		switch metric {
+		case "revenue", "pipeline":
+			point.Value = b.Sum
+		case "avg_deal_size", "cycle_time":
+			if b.Count > 0 {
+				point.Value = b.Sum / float64(b.Count)
+			}
+		case "win_rate":
+			if b.Count > 0 {
+				point.Value = (float64(b.Won) / float64(b.Count)) * 100
+			}
+		case "activities":
+			point.Value = float64(b.Count)
+		}
+		response.Series = append(response.Series, point)
+	}
+
+	switch metric {
+	case "revenue":
+		for _, b := range buckets {
+			point := newTrendPoint(b, granularity, cal)
+			point.Value = as.targetForRange(b.Start, b.End)
+			response.TargetSeries = append(response.TargetSeries, point)
+		}
+	case "pipeline":
+		for _, b := range buckets {
+			nextQuarter, nextYear := cal.QuarterOf(cal.quarterStart(b.End).AddDate(0, 3, 0))
+			point := newTrendPoint(b, granularity, cal)
+			point.Value = as.DataService.GetQuarterTarget(nextQuarter, nextYear) * as.Thresholds.PipelineCoverageTarget
+			response.TargetSeries = append(response.TargetSeries, point)
+		}
+	}
+
+	return response, nil
+}
+
+func (as *AnalyticsService) closingTrend(metric, granularity string) []*trendBucket {
+	type event struct {
+		Date   time.Time
+		Amount float64
+		Age    int
+		Won    bool
+	}
+
+	events := []event{}
+	for _, deal := range as.DataService.Deals {
+		if deal.Stage != "Closed Won" && deal.Stage != "Closed Lost" {
+			continue
+		}
+		if deal.ClosedAt == nil || *deal.ClosedAt == "" {
+			continue
+		}
+		closed, err := as.DataService.ParseDate(*deal.ClosedAt)
+		if err != nil {
+			continue
+		}
+		won := deal.Stage == "Closed Won"
+		if metric != "win_rate" && (!won || deal.Amount == nil) {
+			continue
+		}
+
+		e := event{Date: closed, Won: won, Age: as.DataService.GetDealAge(deal)}
+		if deal.Amount != nil {
+			e.Amount = *deal.Amount
+		}
+		events = append(events, e)
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+	first, last := events[0].Date, events[0].Date
+	for _, e := range events {
+		first, last = minTime(first, e.Date), maxTime(last, e.Date)
+	}
+
+	buckets, index := makeBuckets(first, last, granularity, as.DataService.Calendar)
+	for _, e := range events {
+		b := buckets[index(e.Date)]
+		b.Count++
+		switch metric {
+		case "revenue", "avg_deal_size":
+			b.Sum += e.Amount
+		case "cycle_time":
+			b.Sum += float64(e.Age)
+		case "win_rate":
+			if e.Won {
+				b.Won++
+			}
+		}
+	}
+
+	return buckets
+}
+
+func (as *AnalyticsService) pipelineTrend(granularity string) []*trendBucket {
+	type span struct {
+		Created time.Time
+		Closed  *time.Time
+		Amount  float64
+	}
+
+	spans := []span{}
+	var first, last time.Time
+	for _, deal := range as.DataService.Deals {
+		if deal.Amount == nil {
+			continue
+		}
+		created, err := as.DataService.ParseDate(deal.CreatedAt)
+		if err != nil {
+			continue
+		}
+
+		s := span{Created: created, Amount: *deal.Amount}
+		if deal.Stage == "Closed Won" || deal.Stage == "Closed Lost" {
+			// Closed deals without a close date can't be placed on the timeline.
+			if deal.ClosedAt == nil || *deal.ClosedAt == "" {
+				continue
+			}
+			closed, err := as.DataService.ParseDate(*deal.ClosedAt)
+			if err != nil {
+				continue
+			}
+			s.Closed = &closed
+		}
+
+		if len(spans) == 0 {
+			first, last = created, created
+		}
+		first, last = minTime(first, created), maxTime(last, created)
+		if s.Closed != nil {
+			last = maxTime(last, *s.Closed)
+		}
+		spans = append(spans, s)
+	}
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	buckets, _ := makeBuckets(first, last, granularity, as.DataService.Calendar)
+	for _, b := range buckets {
+		for _, s := range spans {
+			if s.Created.After(b.End) {
+				continue
+			}
+			if s.Closed != nil && !s.Closed.After(b.End) {
+				continue
+			}
+			b.Sum += s.Amount
+			b.Count++
+		}
+	}
+
+	return buckets
+}
+
+func (as *AnalyticsService) activityTrend(granularity string) []*trendBucket {
+	dates := []time.Time{}
+	for _, activity := range as.DataService.Activities {
+		ts, err := as.DataService.ParseDate(activity.Timestamp)
+		if err == nil {
+			dates = append(dates, ts)
+		}
+	}
+
+	if len(dates) == 0 {
+		return nil
+	}
+	first, last := dates[0], dates[0]
+	for _, d := range dates {
+		first, last = minTime(first, d), maxTime(last, d)
+	}
+
+	buckets, index := makeBuckets(first, last, granularity, as.DataService.Calendar)
+	for _, d := range dates {
+		buckets[index(d)].Count++
+	}
+
+	return buckets
+}
+
+// targetForRange spreads each monthly target evenly over the month's days and
+// sums the days that fall within [start, end].
+func (as *AnalyticsService) targetForRange(start, end time.Time) float64 {
+	total := 0.0
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		monthStart := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+		total += as.DataService.GetTargetForMonth(day.Format("2006-01")) / float64(daysInMonth)
+	}
+	return total
+}
+
+// makeBuckets returns contiguous buckets covering [first, last], including
+// empty ones, and a function mapping a date in that range to its bucket index.
+func makeBuckets(first, last time.Time, granularity string, cal FiscalCalendar) ([]*trendBucket, func(time.Time) int) {
+	buckets := []*trendBucket{}
+	for start := bucketStart(first, granularity, cal); !start.After(last); start = nextBucketStart(start, granularity) {
+		buckets = append(buckets, &trendBucket{
+			Start: start,
+			End:   nextBucketStart(start, granularity).AddDate(0, 0, -1),
+		})
+	}
+
+	origin := buckets[0].Start
+	index := func(t time.Time) int {
+		start := bucketStart(t, granularity, cal)
+		switch granularity {
+		case "week":
+			return int(start.Sub(origin).Hours() / (24 * 7))
+		case "month":
+			return (start.Year()-origin.Year())*12 + int(start.Month()-origin.Month())
+		default:
+			return ((start.Year()-origin.Year())*12 + int(start.Month()-origin.Month())) / 3
+		}
+	}
+
+	return buckets, index
+}
+
+func bucketStart(t time.Time, granularity string, cal FiscalCalendar) time.Time {
+	switch granularity {
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return cal.quarterStart(t)
+	}
+}
+
+func nextBucketStart(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 3, 0)
+	}
+}
+
+func (c FiscalCalendar) quarterStart(t time.Time) time.Time {
+	start, _ := c.QuarterRange(c.QuarterOf(t))
+	return start
+}
+
+func newTrendPoint(b *trendBucket, granularity string, cal FiscalCalendar) models.TrendPoint {
+	var period string
+	switch granularity {
+	case "week":
+		year, week := b.Start.ISOWeek()
+		period = fmt.Sprintf("%d-W%02d", year, week)
+	case "month":
+		period = b.Start.Format("2006-01")
+	default:
+		quarter, year := cal.QuarterOf(b.Start)
+		period = fmt.Sprintf("%d-Q%d", year, quarter)
+	}
+
+	return models.TrendPoint{
+		Period:    period,
+		StartDate: b.Start.Format("2006-01-02"),
+		EndDate:   b.End.Format("2006-01-02"),
+		Count:     b.Count,
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
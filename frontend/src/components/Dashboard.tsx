@@ -11,31 +11,17 @@ import RiskFactorsCard from "./RiskFactorsCard";
 import RecommendationsCard from "./RecommendationsCard";
 
 const Dashboard = () => {
-	const {
-		data: summary,
-		isLoading: summaryLoading,
-		error: summaryError,
-	} = useQuery({
-		queryKey: ["summary"],
-		queryFn: api.getSummary,
+	// One request computes every panel from the same data and as-of date.
+	const { data: dashboard, isLoading, error } = useQuery({
+		queryKey: ["dashboard"],
+		queryFn: api.getDashboard,
 	});
+	const summary = dashboard?.summary;
+	const drivers = dashboard?.drivers;
+	const risks = dashboard?.risk_factors;
+	const recommendations = dashboard?.recommendations;
 
-	const { data: drivers, isLoading: driversLoading } = useQuery({
-		queryKey: ["drivers"],
-		queryFn: api.getDrivers,
-	});
-
-	const { data: risks, isLoading: risksLoading } = useQuery({
-		queryKey: ["risks"],
-		queryFn: api.getRiskFactors,
-	});
-
-	const { data: recommendations, isLoading: recommendationsLoading } = useQuery({
-		queryKey: ["recommendations"],
-		queryFn: api.getRecommendations,
-	});
-
-	if (summaryLoading || driversLoading || risksLoading || recommendationsLoading) {
+	if (isLoading) {
 		return (
 			<Box display="flex" justifyContent="center" alignItems="center" minHeight="100vh">
 				<CircularProgress size={60} />
@@ -43,7 +29,7 @@ const Dashboard = () => {
 		);
 	}
 
-	if (summaryError) {
+	if (error) {
 		return (
 			<Container maxWidth="lg" sx={{ mt: 4 }}>
 				<Alert severity="error">Failed to load dashboard data. Please ensure the backend server is running on http://localhost:8080</Alert>
@@ -34,7 +34,20 @@ export interface Recommendation {
   description: string;
 }
 
+export interface DashboardData {
+  as_of_date: string;
+  summary: SummaryData;
+  drivers: RevenueDrivers;
+  risk_factors: RiskFactor[];
+  recommendations: Recommendation[];
+}
+
 export const api = {
+  getDashboard: async (): Promise<DashboardData> => {
+    const response = await axios.get(`${API_BASE_URL}/dashboard`);
+    return response.data;
+  },
+
   getSummary: async (): Promise<SummaryData> => {
     const response = await axios.get(`${API_BASE_URL}/summary`);
     return response.data;